@@ -0,0 +1,268 @@
+package backup
+
+import (
+	"archive/zip"
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+	"backend/validation"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// FormatVersion is the version of the archive layout written by Dump.
+// Increase it whenever the layout changes in a way older versions cannot read.
+const FormatVersion = 1
+
+const (
+	FormatJSON = "json"
+	FormatZIP  = "zip"
+
+	// zipEntryName is the file holding the JSON archive inside a ZIP archive
+	zipEntryName = "backup.json"
+)
+
+// Conflict strategies for datasets in the archive whose name already exists in the database
+const (
+	StrategySkip      = "skip"
+	StrategyOverwrite = "overwrite"
+	StrategyDuplicate = "duplicate"
+)
+
+// Actions taken for a restored dataset
+const (
+	ActionCreated     = "created"
+	ActionSkipped     = "skipped"
+	ActionOverwritten = "overwritten"
+)
+
+var (
+	ErrInvalidFormat      = errors.New("invalid archive format, expected json or zip")
+	ErrInvalidStrategy    = errors.New("invalid conflict strategy, expected skip, overwrite or duplicate")
+	ErrUnsupportedVersion = errors.New("unsupported archive version")
+	ErrInvalidArchive     = errors.New("invalid archive")
+)
+
+// Dump reads the datasets a user owns, or all datasets if userID is 0, and their entries into a backup.
+// Datasets shared with the user are left out, as restoring them would make the user their owner.
+func Dump(store database.Store, userID int) (models.Backup, error) {
+	b := models.Backup{Version: FormatVersion, CreatedAt: time.Now().UTC(), Datasets: []models.DatasetExport{}}
+
+	datasets, err := store.ListDatasets(userID)
+	if err != nil {
+		return b, err
+	}
+	for _, d := range datasets {
+		if userID != 0 && d.OwnerId != userID {
+			continue
+		}
+		entries, err := store.ListEntriesByDataset(d.Id)
+		if err != nil {
+			return b, err
+		}
+		if entries == nil {
+			entries = []models.Entry{}
+		}
+		b.Datasets = append(b.Datasets, models.DatasetExport{Dataset: d, Entries: entries})
+	}
+	return b, nil
+}
+
+// WriteArchive writes the backup as plain JSON or as a ZIP file containing the JSON
+func WriteArchive(w io.Writer, b models.Backup, format string) error {
+	switch format {
+	case FormatJSON:
+		return json.NewEncoder(w).Encode(b)
+	case FormatZIP:
+		zw := zip.NewWriter(w)
+		f, err := zw.Create(zipEntryName)
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(f).Encode(b); err != nil {
+			return err
+		}
+		return zw.Close()
+	default:
+		return ErrInvalidFormat
+	}
+}
+
+// ReadArchive reads a backup written by WriteArchive, detecting whether it is plain JSON or ZIP.
+// The JSON may be at most maxSize bytes, also once decompressed from a ZIP archive, so a small
+// archive cannot expand into more data than the caller is willing to hold in memory.
+func ReadArchive(r io.Reader, maxSize int64) (models.Backup, error) {
+	var b models.Backup
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return b, err
+	}
+
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		if data, err = readZipEntry(data, maxSize); err != nil {
+			return b, err
+		}
+	}
+
+	if err := json.Unmarshal(data, &b); err != nil {
+		return b, fmt.Errorf("%w: %s", ErrInvalidArchive, err.Error())
+	}
+	if b.Version < 1 || b.Version > FormatVersion {
+		return b, fmt.Errorf("%w %d, this version reads up to %d", ErrUnsupportedVersion, b.Version, FormatVersion)
+	}
+	return b, nil
+}
+
+// readZipEntry returns the decompressed backup JSON of a ZIP archive, failing if it exceeds maxSize bytes.
+// The size recorded in the archive is checked first, so oversized entries are not decompressed at all.
+func readZipEntry(data []byte, maxSize int64) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidArchive, err.Error())
+	}
+	tooLarge := fmt.Errorf("%w: %s exceeds %d bytes", ErrInvalidArchive, zipEntryName, maxSize)
+	for _, zf := range zr.File {
+		if zf.Name == zipEntryName && zf.UncompressedSize64 > uint64(maxSize) {
+			return nil, tooLarge
+		}
+	}
+
+	f, err := zr.Open(zipEntryName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidArchive, zipEntryName)
+	}
+	defer func(f io.Closer) {
+		if err := f.Close(); err != nil {
+			utils.Error(err.Error())
+		}
+	}(f)
+	data, err = io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidArchive, err.Error())
+	}
+	if int64(len(data)) > maxSize {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
+// ValidStrategy reports whether strategy is a supported conflict strategy
+func ValidStrategy(strategy string) bool {
+	return strategy == StrategySkip || strategy == StrategyOverwrite || strategy == StrategyDuplicate
+}
+
+// Restore validates the datasets and entries of the backup like the API does and inserts them in a single transaction.
+// If any of them is invalid, nothing is restored and a *validation.Error lists the invalid fields of every row.
+// Datasets and entries get new IDs, the report maps each archived dataset ID to its new one.
+// Restored datasets belong to ownerID, which may be 0 only as long as no user exists.
+// A dataset conflicts if a dataset of the owner with the same name exists, strategy decides whether it is
+// skipped, overwritten including its entries, or restored as a duplicate next to it.
+func Restore(store database.Store, b models.Backup, strategy string, ownerID int) (models.RestoreReport, error) {
+	report := models.RestoreReport{Strategy: strategy, Datasets: []models.RestoredDataset{}}
+	if !ValidStrategy(strategy) {
+		return report, ErrInvalidStrategy
+	}
+	if err := validateBackup(b); err != nil {
+		return report, err
+	}
+
+	err := store.InTransaction(func(tx database.Store) error {
+		for _, archived := range b.Datasets {
+			restored, err := restoreDataset(tx, archived, strategy, ownerID)
+			if err != nil {
+				return fmt.Errorf("restoring dataset %q: %w", archived.Dataset.Name, err)
+			}
+			report.Datasets = append(report.Datasets, restored)
+		}
+		return nil
+	})
+	if err != nil {
+		report.Datasets = []models.RestoredDataset{}
+	}
+	return report, err
+}
+
+// validateBackup checks every dataset and entry of the backup, except projected entries which are not restored.
+// Field names are prefixed with the position of their row, e.g. datasets[0].entries[2].value.
+func validateBackup(b models.Backup) error {
+	invalid := &validation.Error{}
+	for i := range b.Datasets {
+		archived := &b.Datasets[i]
+		prefix := fmt.Sprintf("datasets[%d]", i)
+		addFieldErrors(invalid, prefix, validation.Dataset(&archived.Dataset))
+		for j := range archived.Entries {
+			if archived.Entries[j].Projected {
+				continue
+			}
+			addFieldErrors(invalid, fmt.Sprintf("%s.entries[%d]", prefix, j), validation.Entry(&archived.Entries[j], &archived.Dataset))
+		}
+	}
+	if len(invalid.Fields) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// addFieldErrors adds the invalid fields of err to invalid, prefixing each field name
+func addFieldErrors(invalid *validation.Error, prefix string, err error) {
+	var fieldErr *validation.Error
+	if !errors.As(err, &fieldErr) {
+		return
+	}
+	for _, f := range fieldErr.Fields {
+		invalid.Fields = append(invalid.Fields, models.FieldError{Field: prefix + "." + f.Field, Message: f.Message})
+	}
+}
+
+// restoreDataset restores a single archived dataset and its entries
+func restoreDataset(tx database.Store, archived models.DatasetExport, strategy string, ownerID int) (models.RestoredDataset, error) {
+	d := archived.Dataset
+	d.OwnerId = ownerID
+	restored := models.RestoredDataset{ArchiveId: d.Id, Name: d.Name}
+	if d.Kind == "" {
+		d.Kind = models.KindCumulative
+	}
+
+	existing, err := tx.FindDatasetByName(ownerID, d.Name)
+	switch {
+	case errors.Is(err, database.ErrNotFound) || (err == nil && strategy == StrategyDuplicate):
+		id, err := tx.CreateDataset(&d)
+		if err != nil {
+			return restored, err
+		}
+		d.Id = id
+		restored.Action = ActionCreated
+	case err != nil:
+		return restored, err
+	case strategy == StrategySkip:
+		restored.Id = existing.Id
+		restored.Action = ActionSkipped
+		return restored, nil
+	default:
+		d.Id, d.Version = existing.Id, 0
+		if err := tx.UpdateDataset(&d); err != nil {
+			return restored, err
+		}
+		if err := tx.DeleteEntriesByDataset(d.Id); err != nil {
+			return restored, err
+		}
+		restored.Action = ActionOverwritten
+	}
+
+	restored.Id = d.Id
+	for _, e := range archived.Entries {
+		if e.Projected {
+			continue
+		}
+		e.DatasetId = d.Id
+		if _, err := tx.CreateEntry(&e); err != nil {
+			return restored, err
+		}
+		restored.Entries++
+	}
+	return restored, nil
+}
@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"archive/zip"
+	"backend/database"
+	"backend/models"
+	"backend/validation"
+	"bytes"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createUser(t *testing.T, store database.Store, username string) int {
+	t.Helper()
+	id, err := store.CreateUser(&models.User{Username: username})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestDumpLeavesOutSharedDatasets(t *testing.T) {
+	store := database.NewMemoryStore()
+	owner, viewer := createUser(t, store, "owner"), createUser(t, store, "viewer")
+	shared, err := store.CreateDataset(&models.Dataset{Name: "shared", Kind: models.KindCumulative, OwnerId: owner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetDatasetMember(&models.DatasetMember{DatasetId: shared, UserId: viewer, Role: models.RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateDataset(&models.Dataset{Name: "own", Kind: models.KindCumulative, OwnerId: viewer}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Dump(store, viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Datasets) != 1 || b.Datasets[0].Dataset.Name != "own" {
+		t.Errorf("got %d datasets, want only the dataset the user owns", len(b.Datasets))
+	}
+}
+
+func TestRestoreRejectsInvalidRows(t *testing.T) {
+	store := database.NewMemoryStore()
+	date := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := models.Backup{Version: FormatVersion, Datasets: []models.DatasetExport{
+		{
+			Dataset: models.Dataset{Id: 1, Name: "valid"},
+			Entries: []models.Entry{{Value: 1, Date: date}, {Value: math.NaN(), Date: date}},
+		},
+		{Dataset: models.Dataset{Id: 2, Name: " ", Kind: "unknown"}},
+	}}
+
+	_, err := Restore(store, b, StrategySkip, 0)
+
+	var invalid *validation.Error
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+	var fields []string
+	for _, f := range invalid.Fields {
+		fields = append(fields, f.Field)
+	}
+	for _, want := range []string{"datasets[0].entries[1].value", "datasets[1].name", "datasets[1].kind"} {
+		if !slices.Contains(fields, want) {
+			t.Errorf("got invalid fields %v, want %s", fields, want)
+		}
+	}
+	datasets, err := store.ListDatasets(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(datasets) != 0 {
+		t.Errorf("restored %d datasets, want none", len(datasets))
+	}
+}
+
+func TestReadArchiveRejectsOversizeZipEntry(t *testing.T) {
+	const maxSize = 1 << 10
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	f, err := zw.Create(zipEntryName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Whitespace is valid JSON padding and compresses to almost nothing
+	if _, err := f.Write([]byte(`{"version":1,"datasets":[]}` + strings.Repeat(" ", 100*maxSize))); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if archive.Len() > maxSize {
+		t.Fatalf("compressed archive has %d bytes, want at most %d", archive.Len(), maxSize)
+	}
+
+	_, err = ReadArchive(&archive, maxSize)
+
+	if !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("got %v, want %v", err, ErrInvalidArchive)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"backend/backup"
+	"backend/database"
+	"backend/migrations"
+	"backend/models"
+	"backend/utils"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxArchiveSize limits the size of the backup JSON the restore command decompresses from a ZIP archive.
+// Archives restored from the command line are local files, so the limit is higher than for uploads.
+const maxArchiveSize = 2 << 30
+
+// runCommand runs the subcommand given on the command line instead of starting the server
+func runCommand(store database.Store, args []string) error {
+	switch args[0] {
+	case "backup":
+		return backupCommand(store, args[1:])
+	case "restore":
+		return restoreCommand(store, args[1:])
+	case "migrate":
+		return migrateCommand(store, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, expected backup, restore or migrate", args[0])
+	}
+}
+
+// migrateCommand migrates the schema to the latest or a given version, rolls back migrations or prints the version
+func migrateCommand(store database.Store, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	to := fs.Int("to", -1, "version to migrate up or down to, the latest version if omitted")
+	rollback := fs.Int("rollback", 0, "number of applied migrations to roll back")
+	status := fs.Bool("status", false, "print the current and latest schema version")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sqlStore, ok := store.(*database.SQLStore)
+	if !ok {
+		return errors.New("migrate requires the postgres or sqlite storage backend")
+	}
+	db, backend := sqlStore.DB(), sqlStore.Backend()
+
+	switch {
+	case *status:
+		version, err := migrations.Version(db, backend)
+		if err != nil {
+			return err
+		}
+		utils.Info(fmt.Sprintf("Schema version %d, latest version %d", version, migrations.Latest()))
+		return nil
+	case *rollback > 0 && *to >= 0:
+		return errors.New("migrate accepts either -to or -rollback")
+	case *rollback > 0:
+		return migrations.Rollback(db, backend, *rollback)
+	case *to >= 0:
+		return migrations.MigrateTo(db, backend, *to)
+	default:
+		return migrations.Up(db, backend)
+	}
+}
+
+// backupCommand writes all datasets and entries into an archive file
+func backupCommand(store database.Store, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	file := fs.String("file", "", "path of the archive to write")
+	format := fs.String("format", backup.FormatZIP, "archive format, json or zip")
+	username := fs.String("user", "", "only back up the datasets this user owns")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("backup requires -file")
+	}
+	ownerID, err := lookupOwner(store, *username)
+	if err != nil {
+		return err
+	}
+
+	b, err := backup.Dump(store, ownerID)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	if err := backup.WriteArchive(f, b, *format); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	utils.Success(fmt.Sprintf("Backed up %d datasets to %s", len(b.Datasets), *file))
+	return nil
+}
+
+// restoreCommand restores the datasets and entries of an archive file
+func restoreCommand(store database.Store, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	file := fs.String("file", "", "path of the archive to restore")
+	strategy := fs.String("strategy", backup.StrategySkip, "how to handle datasets whose name exists, skip, overwrite or duplicate")
+	username := fs.String("user", "", "user owning the restored datasets, required once a user is registered")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("restore requires -file")
+	}
+	ownerID, err := lookupOwner(store, *username)
+	if err != nil {
+		return err
+	}
+	if ownerID == 0 {
+		users, err := store.CountUsers()
+		if err != nil {
+			return err
+		}
+		if users > 0 {
+			return errors.New("restore requires -user once a user is registered")
+		}
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		if err := f.Close(); err != nil {
+			utils.Error(err.Error())
+		}
+	}(f)
+
+	b, err := backup.ReadArchive(f, maxArchiveSize)
+	if err != nil {
+		return err
+	}
+	actor := models.Actor{UserId: ownerID, Name: "restore command"}
+	report, err := backup.Restore(database.NewAuditedStore(store, actor), b, *strategy, ownerID)
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	utils.Success(fmt.Sprintf("Restored %d datasets from %s\n%s", len(report.Datasets), *file, out))
+	return nil
+}
+
+// lookupOwner returns the ID of the user with the given username, or 0 if username is empty
+func lookupOwner(store database.Store, username string) (int, error) {
+	if username == "" {
+		return 0, nil
+	}
+	u, err := store.FindUserByUsername(strings.ToLower(username))
+	if errors.Is(err, database.ErrNotFound) {
+		return 0, fmt.Errorf("unknown user %q", username)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return u.Id, nil
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"backend/models"
+	"backend/utils"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// bucketIntervals maps the supported aggregation buckets to the Postgres interval between them.
+// The bucket name itself is the date_trunc field.
+var bucketIntervals = map[string]string{
+	"day":     "1 day",
+	"week":    "1 week",
+	"month":   "1 month",
+	"quarter": "3 months",
+	"year":    "1 year",
+}
+
+// aggregateFunctions maps the supported aggregation functions to their SQL expression.
+// Functions with a meaningful value for empty buckets coalesce to it, the others stay NULL.
+var aggregateFunctions = map[string]string{
+	"sum":   "COALESCE(SUM(e.value), 0)",
+	"avg":   "AVG(e.value)",
+	"min":   "MIN(e.value)",
+	"max":   "MAX(e.value)",
+	"last":  "(ARRAY_AGG(e.value ORDER BY e.date DESC))[1]",
+	"count": "COUNT(e.id)",
+}
+
+// ValidBucket reports whether bucket is a supported aggregation bucket
+func ValidBucket(bucket string) bool {
+	_, ok := bucketIntervals[bucket]
+	return ok
+}
+
+// ValidAggregateFunction reports whether fn is a supported aggregation function
+func ValidAggregateFunction(fn string) bool {
+	_, ok := aggregateFunctions[fn]
+	return ok
+}
+
+// AggregateEntries groups the entries of a dataset into buckets and aggregates their values with fn.
+// Every bucket between the first and the last entry is returned, including empty ones.
+// Returns the points ordered by bucket on success or an error on failure
+func (s *SQLStore) AggregateEntries(datasetID int, bucket, fn string) ([]models.SeriesPoint, error) {
+	interval, ok := bucketIntervals[bucket]
+	if !ok {
+		return nil, fmt.Errorf("unsupported bucket %q", bucket)
+	}
+	expression, ok := aggregateFunctions[fn]
+	if !ok {
+		return nil, fmt.Errorf("unsupported aggregate function %q", fn)
+	}
+
+	// SQLite has neither date_trunc nor generate_series, so its entries are aggregated in Go
+	if s.dialect.name != BackendPostgres {
+		entries, err := s.ListEntriesByDataset(datasetID)
+		if err != nil {
+			return nil, err
+		}
+		return aggregateEntries(entries, bucket, fn), nil
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		WITH buckets AS (
+			SELECT generate_series(date_trunc($2, MIN(date)), date_trunc($2, MAX(date)), $3::interval) AS bucket
+			FROM entries
+			WHERE dataset_id = $1 AND deleted_at IS NULL
+		)
+		SELECT b.bucket, %s, COUNT(e.id)
+		FROM buckets b
+		LEFT JOIN entries e ON e.dataset_id = $1 AND e.deleted_at IS NULL AND date_trunc($2, e.date) = b.bucket
+		GROUP BY b.bucket
+		ORDER BY b.bucket
+	`, expression), datasetID, bucket, interval)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			utils.Error(err.Error())
+		}
+	}(rows)
+
+	points := []models.SeriesPoint{}
+	for rows.Next() {
+		var p models.SeriesPoint
+		if err := rows.Scan(&p.Bucket, &p.Value, &p.Count); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// aggregateEntries aggregates entries like the Postgres query of AggregateEntries does.
+// Buckets start at midnight UTC of the wall-clock date, weeks start on Monday.
+func aggregateEntries(entries []models.Entry, bucket, fn string) []models.SeriesPoint {
+	if len(entries) == 0 {
+		return []models.SeriesPoint{}
+	}
+
+	first, last := entries[0].Date, entries[0].Date
+	for _, e := range entries {
+		if e.Date.Before(first) {
+			first = e.Date
+		}
+		if e.Date.After(last) {
+			last = e.Date
+		}
+	}
+
+	var points []models.SeriesPoint
+	index := map[time.Time]int{}
+	for b := truncateToBucket(first, bucket); !b.After(truncateToBucket(last, bucket)); b = nextBucket(b, bucket) {
+		index[b] = len(points)
+		points = append(points, models.SeriesPoint{Bucket: b})
+	}
+
+	lastDates := make([]time.Time, len(points))
+	for _, e := range entries {
+		i := index[truncateToBucket(e.Date, bucket)]
+		p := &points[i]
+		p.Count++
+		value := e.Value
+		switch {
+		case p.Value == nil:
+			p.Value = &value
+			lastDates[i] = e.Date
+		case fn == "sum" || fn == "avg":
+			*p.Value += value
+		case fn == "min" && value < *p.Value, fn == "max" && value > *p.Value:
+			*p.Value = value
+		case fn == "last" && !e.Date.Before(lastDates[i]):
+			*p.Value = value
+			lastDates[i] = e.Date
+		}
+	}
+
+	for i := range points {
+		p := &points[i]
+		switch fn {
+		case "sum":
+			if p.Value == nil {
+				p.Value = new(float64)
+			}
+		case "avg":
+			if p.Value != nil {
+				*p.Value /= float64(p.Count)
+			}
+		case "count":
+			count := float64(p.Count)
+			p.Value = &count
+		}
+	}
+	return points
+}
+
+// truncateToBucket returns the start of the bucket containing the wall-clock time of t
+func truncateToBucket(t time.Time, bucket string) time.Time {
+	t = wallClock(t)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch bucket {
+	case "week":
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "quarter":
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	case "year":
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// nextBucket returns the start of the bucket following the one starting at b
+func nextBucket(b time.Time, bucket string) time.Time {
+	switch bucket {
+	case "week":
+		return b.AddDate(0, 0, 7)
+	case "month":
+		return b.AddDate(0, 1, 0)
+	case "quarter":
+		return b.AddDate(0, 3, 0)
+	case "year":
+		return b.AddDate(1, 0, 0)
+	default:
+		return b.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,81 @@
+package database_test
+
+import (
+	"backend/database"
+	"backend/migrations"
+	"backend/models"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// errRollback discards the changes a test made inside InTransaction
+var errRollback = errors.New("rollback")
+
+// testStores returns a migrated store of every backend available to the tests.
+// Postgres is only tested if TEST_POSTGRES_URL is set.
+func testStores(t *testing.T) map[string]database.Store {
+	t.Helper()
+	stores := map[string]database.Store{database.BackendMemory: database.NewMemoryStore()}
+
+	sqlite, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_pragma=foreign_keys(1)&_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlite.Close() })
+	stores[database.BackendSQLite] = migratedStore(t, sqlite, database.BackendSQLite)
+
+	if url := os.Getenv("TEST_POSTGRES_URL"); url != "" {
+		postgres, err := sql.Open("postgres", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = postgres.Close() })
+		stores[database.BackendPostgres] = migratedStore(t, postgres, database.BackendPostgres)
+	} else {
+		t.Log("TEST_POSTGRES_URL is not set, skipping postgres")
+	}
+	return stores
+}
+
+func migratedStore(t *testing.T, db *sql.DB, backend string) database.Store {
+	t.Helper()
+	if err := migrations.Up(db, backend); err != nil {
+		t.Fatal(err)
+	}
+	store, err := database.NewSQLStore(db, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestAggregateEntriesOfEmptyDataset(t *testing.T) {
+	for backend, store := range testStores(t) {
+		t.Run(backend, func(t *testing.T) {
+			err := store.InTransaction(func(tx database.Store) error {
+				id, err := tx.CreateDataset(&models.Dataset{Name: "empty", Kind: models.KindCumulative})
+				if err != nil {
+					t.Fatal(err)
+				}
+				points, err := tx.AggregateEntries(id, "month", "sum")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if points == nil || len(points) != 0 {
+					t.Errorf("got %#v, want an empty slice", points)
+				}
+				return errRollback
+			})
+			if !errors.Is(err, errRollback) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"backend/models"
+	"backend/utils"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CreateAlertRule stores a new alert rule of a dataset
+// Returns the ID of the new rule on success, or an error on failure
+func (s *SQLStore) CreateAlertRule(rule *models.AlertRule) (int, error) {
+	var id int
+	err := s.db.QueryRow(`
+		INSERT INTO alert_rules (dataset_id, name, condition, threshold, direction, days, method, notifier, target,
+		                         created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id
+	`, rule.DatasetId, rule.Name, rule.Condition, rule.Threshold, rule.Direction, rule.Days, rule.Method, rule.Notifier,
+		rule.Target, rule.CreatedBy, s.dialect.storeTime(rule.CreatedAt.UTC())).Scan(&id)
+	return id, err
+}
+
+// ListAlertRules returns the alert rules of a dataset, or of all datasets not in the trash if datasetID is 0,
+// ordered by dataset and ID
+// Returns a list of rules on success or an error on failure
+func (s *SQLStore) ListAlertRules(datasetID int) ([]models.AlertRule, error) {
+	rows, err := s.db.Query(`
+		SELECT `+alertRuleColumns+`
+		FROM alert_rules
+		WHERE dataset_id = $1 OR $1 = 0 AND dataset_id IN (SELECT id FROM datasets WHERE deleted_at IS NULL)
+		ORDER BY dataset_id, id
+	`, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			utils.Error(err.Error())
+		}
+	}(rows)
+
+	rules := []models.AlertRule{}
+	for rows.Next() {
+		var rule models.AlertRule
+		if err := scanAlertRule(&rule, rows); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteAlertRule deletes an alert rule of a dataset, the alerts it raised are kept
+// Returns sql.ErrNoRows if the dataset has no such rule, or an error on failure
+func (s *SQLStore) DeleteAlertRule(datasetID, id int) error {
+	res, err := s.db.Exec(`DELETE FROM alert_rules WHERE id = $1 AND dataset_id = $2`, id, datasetID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// SetAlertRuleFiring records whether the condition of an alert rule holds, and the time it was triggered if set
+// Returns an error on failure
+func (s *SQLStore) SetAlertRuleFiring(id int, firing bool, triggeredAt *time.Time) error {
+	var triggered any
+	if triggeredAt != nil {
+		triggered = s.dialect.storeTime(triggeredAt.UTC())
+	}
+	_, err := s.db.Exec(`
+		UPDATE alert_rules SET firing = $1, last_triggered_at = COALESCE($2, last_triggered_at) WHERE id = $3
+	`, firing, triggered, id)
+	return err
+}
+
+// CreateAlert stores an alert raised by a rule
+// Returns the ID of the new alert on success, or an error on failure
+func (s *SQLStore) CreateAlert(a *models.Alert) (int, error) {
+	var id int
+	err := s.db.QueryRow(`
+		INSERT INTO alerts (rule_id, dataset_id, rule_name, condition, message, value, notifier, delivered, error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id
+	`, a.RuleId, a.DatasetId, a.RuleName, a.Condition, a.Message, a.Value, a.Notifier, a.Delivered, a.Error,
+		s.dialect.storeTime(a.CreatedAt.UTC())).Scan(&id)
+	return id, err
+}
+
+// ListAlerts returns the alerts raised for a dataset, only those of one rule if filter.RuleId is set, newest first
+// Returns a list of alerts on success or an error on failure
+func (s *SQLStore) ListAlerts(filter models.AlertFilter) ([]models.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE dataset_id = $1 AND ($2 = 0 OR rule_id = $2) ORDER BY id DESC`
+	args := []any{filter.DatasetId, filter.RuleId}
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		if filter.Limit <= 0 {
+			query += " LIMIT " + s.dialect.noLimit
+		}
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			utils.Error(err.Error())
+		}
+	}(rows)
+
+	alerts := []models.Alert{}
+	for rows.Next() {
+		var a models.Alert
+		if err := scanAlert(&a, rows); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// alertRuleColumns are the columns scanAlertRule reads, in order
+const alertRuleColumns = "id, dataset_id, name, condition, threshold, direction, days, method, notifier, target, firing, " +
+	"last_triggered_at, created_by, created_at"
+
+func scanAlertRule(rule *models.AlertRule, row rowScanner) error {
+	return row.Scan(&rule.Id, &rule.DatasetId, &rule.Name, &rule.Condition, &rule.Threshold, &rule.Direction, &rule.Days,
+		&rule.Method, &rule.Notifier, &rule.Target, &rule.Firing, &rule.LastTriggeredAt, &rule.CreatedBy, &rule.CreatedAt)
+}
+
+// alertColumns are the columns scanAlert reads, in order
+const alertColumns = "id, rule_id, dataset_id, rule_name, condition, message, value, notifier, delivered, error, created_at"
+
+func scanAlert(a *models.Alert, row rowScanner) error {
+	return row.Scan(&a.Id, &a.RuleId, &a.DatasetId, &a.RuleName, &a.Condition, &a.Message, &a.Value, &a.Notifier,
+		&a.Delivered, &a.Error, &a.CreatedAt)
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"backend/models"
+	"backend/utils"
+	"database/sql"
+	"time"
+)
+
+// CreateAPIKey stores a new API key, identified by the hash of the key
+// Returns the ID of the new key on success, or an error on failure
+func (s *SQLStore) CreateAPIKey(k *models.APIKey) (int, error) {
+	var id int
+	err := s.db.QueryRow(`
+		INSERT INTO api_keys (dataset_id, name, scope, prefix, key_hash, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id
+	`, k.DatasetId, k.Name, k.Scope, k.Prefix, k.KeyHash, k.CreatedBy, s.dialect.storeTime(k.CreatedAt.UTC())).Scan(&id)
+	return id, err
+}
+
+// GetAPIKeyByHash returns the API key with the given hash, whether it is revoked or not
+// Returns the key on success or sql.ErrNoRows if there is none
+func (s *SQLStore) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	k := &models.APIKey{}
+	err := scanAPIKey(k, s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, keyHash))
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// ListAPIKeys returns the API keys of a dataset ordered by ID, including revoked ones
+// Returns a list of keys on success or an error on failure
+func (s *SQLStore) ListAPIKeys(datasetID int) ([]models.APIKey, error) {
+	rows, err := s.db.Query(`SELECT `+apiKeyColumns+` FROM api_keys WHERE dataset_id = $1 ORDER BY id`, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			utils.Error(err.Error())
+		}
+	}(rows)
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var k models.APIKey
+		if err := scanAPIKey(&k, rows); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey marks an API key of a dataset as revoked
+// Returns sql.ErrNoRows if the dataset has no such key or it is revoked already, or an error on failure
+func (s *SQLStore) RevokeAPIKey(datasetID, id int, now time.Time) error {
+	res, err := s.db.Exec(`
+		UPDATE api_keys SET revoked_at = $1
+		WHERE id = $2 AND dataset_id = $3 AND revoked_at IS NULL
+	`, s.dialect.storeTime(now.UTC()), id, datasetID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// TouchAPIKey sets the time an API key was last used
+// Returns an error on failure
+func (s *SQLStore) TouchAPIKey(id int, now time.Time) error {
+	_, err := s.db.Exec(`UPDATE api_keys SET last_used_at = $1 WHERE id = $2`, s.dialect.storeTime(now.UTC()), id)
+	return err
+}
+
+// apiKeyColumns are the columns scanAPIKey reads, in order
+const apiKeyColumns = "id, dataset_id, name, scope, prefix, key_hash, created_by, created_at, last_used_at, revoked_at"
+
+func scanAPIKey(k *models.APIKey, row rowScanner) error {
+	return row.Scan(&k.Id, &k.DatasetId, &k.Name, &k.Scope, &k.Prefix, &k.KeyHash, &k.CreatedBy, &k.CreatedAt,
+		&k.LastUsedAt, &k.RevokedAt)
+}
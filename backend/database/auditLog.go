@@ -0,0 +1,85 @@
+package database
+
+import (
+	"backend/models"
+	"backend/utils"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// AppendAuditEvent appends an event to the audit log
+// Returns an error on failure
+func (s *SQLStore) AppendAuditEvent(e *models.AuditEvent) error {
+	_, err := s.db.Exec(`
+		INSERT INTO audit_log (dataset_id, entry_id, entity, action, user_id, api_key_id, actor, old_value, new_value, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, e.DatasetId, optionalID(e.EntryId), e.Entity, e.Action, optionalID(e.UserId), optionalID(e.APIKeyId), e.Actor,
+		optionalJSON(e.OldValue), optionalJSON(e.NewValue), s.dialect.storeTime(e.CreatedAt.UTC()))
+	return err
+}
+
+// ListAuditEvents returns the audit events of an entry if filter.EntryId is set, or else of a dataset
+// including its entries, newest first
+// Returns a list of events on success or an error on failure
+func (s *SQLStore) ListAuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	column, id := "dataset_id", filter.DatasetId
+	if filter.EntryId != 0 {
+		column, id = "entry_id", filter.EntryId
+	}
+	query := `SELECT ` + auditColumns + ` FROM audit_log WHERE ` + column + ` = $1 ORDER BY id DESC`
+	args := []any{id}
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		if filter.Limit <= 0 {
+			query += " LIMIT " + s.dialect.noLimit
+		}
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			utils.Error(err.Error())
+		}
+	}(rows)
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var e models.AuditEvent
+		if err := scanAuditEvent(&e, rows); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// optionalJSON converts an optional JSON document for a nullable JSON column
+func optionalJSON(raw json.RawMessage) any {
+	if raw == nil {
+		return nil
+	}
+	return string(raw)
+}
+
+// auditColumns are the columns scanAuditEvent reads, in order
+const auditColumns = "id, dataset_id, entry_id, entity, action, user_id, api_key_id, actor, old_value, new_value, created_at"
+
+func scanAuditEvent(e *models.AuditEvent, row rowScanner) error {
+	var entryID, userID, apiKeyID sql.NullInt64
+	var oldValue, newValue []byte
+	err := row.Scan(&e.Id, &e.DatasetId, &entryID, &e.Entity, &e.Action, &userID, &apiKeyID, &e.Actor,
+		&oldValue, &newValue, &e.CreatedAt)
+	e.EntryId, e.UserId, e.APIKeyId = int(entryID.Int64), int(userID.Int64), int(apiKeyID.Int64)
+	e.OldValue, e.NewValue = oldValue, newValue
+	return err
+}
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"backend/models"
+	"encoding/json"
+	"time"
+)
+
+// AuditedStore is a Store appending every change of a dataset or an entry to the audit log,
+// together with the old and new values and the actor making the change.
+// Each change and its audit event are stored in one transaction.
+type AuditedStore struct {
+	Store
+	actor models.Actor
+}
+
+// NewAuditedStore returns a store recording the changes made through it as changes of actor
+func NewAuditedStore(store Store, actor models.Actor) *AuditedStore {
+	return &AuditedStore{Store: store, actor: actor}
+}
+
+func (a *AuditedStore) InTransaction(fn func(tx Store) error) error {
+	return a.Store.InTransaction(func(tx Store) error {
+		return fn(&AuditedStore{Store: tx, actor: a.actor})
+	})
+}
+
+func (a *AuditedStore) CreateDataset(d *models.Dataset) (int, error) {
+	var id int
+	err := a.Store.InTransaction(func(tx Store) error {
+		var err error
+		if id, err = tx.CreateDataset(d); err != nil {
+			return err
+		}
+		created, err := tx.GetDataset(id)
+		if err != nil {
+			return err
+		}
+		return a.record(tx, id, 0, models.AuditDataset, models.AuditCreate, nil, created)
+	})
+	return id, err
+}
+
+func (a *AuditedStore) UpdateDataset(d *models.Dataset) error {
+	return a.Store.InTransaction(func(tx Store) error {
+		old, err := tx.GetDataset(d.Id)
+		if err != nil {
+			return err
+		}
+		if err := tx.UpdateDataset(d); err != nil {
+			return err
+		}
+		updated, err := tx.GetDataset(d.Id)
+		if err != nil {
+			return err
+		}
+		return a.record(tx, d.Id, 0, models.AuditDataset, models.AuditUpdate, old, updated)
+	})
+}
+
+func (a *AuditedStore) DeleteDataset(id, version int) error {
+	return a.Store.InTransaction(func(tx Store) error {
+		old, err := tx.GetDataset(id)
+		if err != nil {
+			return err
+		}
+		if err := tx.DeleteDataset(id, version); err != nil {
+			return err
+		}
+		return a.record(tx, id, 0, models.AuditDataset, models.AuditDelete, old, nil)
+	})
+}
+
+func (a *AuditedStore) RestoreDataset(id int) error {
+	return a.Store.InTransaction(func(tx Store) error {
+		old, err := tx.GetTrashedDataset(id)
+		if err != nil {
+			return err
+		}
+		if err := tx.RestoreDataset(id); err != nil {
+			return err
+		}
+		restored, err := tx.GetDataset(id)
+		if err != nil {
+			return err
+		}
+		return a.record(tx, id, 0, models.AuditDataset, models.AuditRestore, old, restored)
+	})
+}
+
+func (a *AuditedStore) CreateEntry(e *models.Entry) (int, error) {
+	var id int
+	err := a.Store.InTransaction(func(tx Store) error {
+		var err error
+		if id, err = tx.CreateEntry(e); err != nil {
+			return err
+		}
+		created, err := tx.GetEntry(id)
+		if err != nil {
+			return err
+		}
+		return a.record(tx, created.DatasetId, id, models.AuditEntry, models.AuditCreate, nil, created)
+	})
+	return id, err
+}
+
+func (a *AuditedStore) UpdateEntry(e *models.Entry) error {
+	return a.updateEntry(e, func(tx Store) error { return tx.UpdateEntry(e) })
+}
+
+func (a *AuditedStore) UpdateDatasetEntry(e *models.Entry) error {
+	return a.updateEntry(e, func(tx Store) error { return tx.UpdateDatasetEntry(e) })
+}
+
+func (a *AuditedStore) DeleteEntry(id, version int) error {
+	return a.deleteEntry(id, func(tx Store) error { return tx.DeleteEntry(id, version) })
+}
+
+func (a *AuditedStore) DeleteDatasetEntry(datasetID, id, version int) error {
+	return a.deleteEntry(id, func(tx Store) error { return tx.DeleteDatasetEntry(datasetID, id, version) })
+}
+
+func (a *AuditedStore) RestoreEntry(id int) error {
+	return a.Store.InTransaction(func(tx Store) error {
+		old, err := tx.GetTrashedEntry(id)
+		if err != nil {
+			return err
+		}
+		if err := tx.RestoreEntry(id); err != nil {
+			return err
+		}
+		restored, err := tx.GetEntry(id)
+		if err != nil {
+			return err
+		}
+		return a.record(tx, restored.DatasetId, id, models.AuditEntry, models.AuditRestore, old, restored)
+	})
+}
+
+func (a *AuditedStore) DeleteEntriesByDataset(datasetID int) error {
+	return a.Store.InTransaction(func(tx Store) error {
+		entries, err := tx.ListEntriesByDataset(datasetID)
+		if err != nil {
+			return err
+		}
+		if err := tx.DeleteEntriesByDataset(datasetID); err != nil {
+			return err
+		}
+		return a.recordEntryDeletes(tx, entries)
+	})
+}
+
+// PurgeTrash records a purge of each dataset and entry in the trash it deletes.
+// The entries purged together with their dataset are covered by the event of the dataset.
+func (a *AuditedStore) PurgeTrash(before time.Time) (int, error) {
+	var purged int
+	err := a.Store.InTransaction(func(tx Store) error {
+		trash, err := tx.ListTrash(0)
+		if err != nil {
+			return err
+		}
+		for _, d := range trash.Datasets {
+			if d.DeletedAt.Before(before) {
+				if err := a.record(tx, d.Id, 0, models.AuditDataset, models.AuditPurge, d, nil); err != nil {
+					return err
+				}
+			}
+		}
+		for _, e := range trash.Entries {
+			if e.DeletedAt.Before(before) {
+				if err := a.record(tx, e.DatasetId, e.Id, models.AuditEntry, models.AuditPurge, e, nil); err != nil {
+					return err
+				}
+			}
+		}
+		purged, err = tx.PurgeTrash(before)
+		return err
+	})
+	return purged, err
+}
+
+// updateEntry runs update and records the entry before and after it
+func (a *AuditedStore) updateEntry(e *models.Entry, update func(tx Store) error) error {
+	return a.Store.InTransaction(func(tx Store) error {
+		old, err := tx.GetEntry(e.Id)
+		if err != nil {
+			return err
+		}
+		if err := update(tx); err != nil {
+			return err
+		}
+		updated, err := tx.GetEntry(e.Id)
+		if err != nil {
+			return err
+		}
+		return a.record(tx, updated.DatasetId, e.Id, models.AuditEntry, models.AuditUpdate, old, updated)
+	})
+}
+
+// deleteEntry runs del and records the entry it deleted
+func (a *AuditedStore) deleteEntry(id int, del func(tx Store) error) error {
+	return a.Store.InTransaction(func(tx Store) error {
+		old, err := tx.GetEntry(id)
+		if err != nil {
+			return err
+		}
+		if err := del(tx); err != nil {
+			return err
+		}
+		return a.record(tx, old.DatasetId, id, models.AuditEntry, models.AuditDelete, old, nil)
+	})
+}
+
+func (a *AuditedStore) recordEntryDeletes(tx Store, entries []models.Entry) error {
+	for _, e := range entries {
+		if err := a.record(tx, e.DatasetId, e.Id, models.AuditEntry, models.AuditDelete, e, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record appends an audit event with the old and new value of a dataset or entry, nil if there is none
+func (a *AuditedStore) record(tx Store, datasetID, entryID int, entity, action string, oldValue, newValue any) error {
+	e := models.AuditEvent{
+		DatasetId: datasetID,
+		EntryId:   entryID,
+		Entity:    entity,
+		Action:    action,
+		UserId:    a.actor.UserId,
+		APIKeyId:  a.actor.APIKeyId,
+		Actor:     a.actor.Name,
+		CreatedAt: time.Now(),
+	}
+	var err error
+	if e.OldValue, err = marshalAuditValue(oldValue); err != nil {
+		return err
+	}
+	if e.NewValue, err = marshalAuditValue(newValue); err != nil {
+		return err
+	}
+	return tx.AppendAuditEvent(&e)
+}
+
+func marshalAuditValue(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
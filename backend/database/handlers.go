@@ -4,16 +4,20 @@ import (
 	"backend/models"
 	"backend/utils"
 	"database/sql"
+	"fmt"
+	"strings"
+	"time"
 )
 
 // CreateDataset creates a new dataset in the database
 // Returns the ID of the new dataset on success, or an error on failure
-func CreateDataset(db *sql.DB, d *models.Dataset) (int, error) {
+func (s *SQLStore) CreateDataset(d *models.Dataset) (int, error) {
 	var id int
-	err := db.QueryRow(`
-		INSERT INTO datasets (name, description, symbol, target_value, start_date, end_date)
-		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id
-	`, d.Name, d.Description, d.Symbol, d.TargetValue, d.StartDate, d.EndDate).Scan(&id)
+	err := s.db.QueryRow(`
+		INSERT INTO datasets (name, description, symbol, target_value, start_date, end_date, kind, owner_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id
+	`, d.Name, d.Description, d.Symbol, d.TargetValue, s.optionalTime(d.StartDate), s.optionalTime(d.EndDate), d.Kind,
+		optionalID(d.OwnerId)).Scan(&id)
 	if err != nil {
 		utils.Error("Failed to create dataset: " + err.Error())
 		return 0, err
@@ -21,35 +25,46 @@ func CreateDataset(db *sql.DB, d *models.Dataset) (int, error) {
 	return id, nil
 }
 
-// UpdateDataset updates a dataset in the database
-// Returns an error on failure
-func UpdateDataset(db *sql.DB, d *models.Dataset) error {
-	_, err := db.Exec(`
+// UpdateDataset updates a dataset in the database and increments its version.
+// If d.Version is set, the dataset is only updated if it still has that version.
+// Returns ErrConflict if the version differs, or an error on failure
+func (s *SQLStore) UpdateDataset(d *models.Dataset) error {
+	res, err := s.db.Exec(`
 		UPDATE datasets
-		SET name = $1, description = $2, symbol = $3, target_value = $4, start_date = $5, end_date = $6
-		WHERE id = $7
-	`, d.Name, d.Description, d.Symbol, d.TargetValue, d.StartDate, d.EndDate, d.Id)
-	return err
+		SET name = $1, description = $2, symbol = $3, target_value = $4, start_date = $5, end_date = $6, kind = $7,
+		    version = version + 1
+		WHERE id = $8 AND deleted_at IS NULL AND ($9 = 0 OR version = $9)
+	`, d.Name, d.Description, d.Symbol, d.TargetValue, s.optionalTime(d.StartDate), s.optionalTime(d.EndDate), d.Kind, d.Id, d.Version)
+	if err != nil {
+		return err
+	}
+	return s.requireAffected(res, "datasets", d.Id)
 }
 
-// GetDataset returns a dataset from the database by ID
+// GetDataset returns a dataset from the database by ID, unless it is in the trash
 // Returns the dataset on success or an error on failure
-func GetDataset(db *sql.DB, id int) (*models.Dataset, error) {
+func (s *SQLStore) GetDataset(id int) (*models.Dataset, error) {
 	d := &models.Dataset{}
-	err := db.QueryRow(`
-		SELECT id, name, description, symbol, target_value, start_date, end_date
-		FROM datasets WHERE id = $1
-	`, id).Scan(&d.Id, &d.Name, &d.Description, &d.Symbol, &d.TargetValue, &d.StartDate, &d.EndDate)
+	err := scanDataset(d, s.db.QueryRow(`
+		SELECT `+datasetColumns+`
+		FROM datasets WHERE id = $1 AND deleted_at IS NULL
+	`, id))
 	if err != nil {
 		return nil, err
 	}
 	return d, nil
 }
 
-// ListDatasets returns a list of all datasets in the database
+// ListDatasets returns a list of the datasets a user owns or is a member of, or of all datasets if userID is 0
 // Returns a list of datasets on success or an error on failure
-func ListDatasets(db *sql.DB) ([]models.Dataset, error) {
-	rows, err := db.Query(`SELECT id, name, description, symbol, target_value, start_date, end_date FROM datasets`)
+func (s *SQLStore) ListDatasets(userID int) ([]models.Dataset, error) {
+	rows, err := s.db.Query(`
+		SELECT `+datasetColumns+`
+		FROM datasets
+		WHERE deleted_at IS NULL
+		  AND ($1 = 0 OR owner_id = $1 OR id IN (SELECT dataset_id FROM dataset_members WHERE user_id = $1))
+		ORDER BY id
+	`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +78,7 @@ func ListDatasets(db *sql.DB) ([]models.Dataset, error) {
 	var datasets []models.Dataset
 	for rows.Next() {
 		var d models.Dataset
-		if err := rows.Scan(&d.Id, &d.Name, &d.Description, &d.Symbol, &d.TargetValue, &d.StartDate, &d.EndDate); err != nil {
+		if err := scanDataset(&d, rows); err != nil {
 			return nil, err
 		}
 		datasets = append(datasets, d)
@@ -71,21 +86,50 @@ func ListDatasets(db *sql.DB) ([]models.Dataset, error) {
 	return datasets, nil
 }
 
-// DeleteDataset deletes a dataset from the database by ID
+// FindDatasetByName returns the dataset with the lowest ID among those of an owner named name,
+// any owner matches if ownerID is 0
+// Returns the dataset on success or sql.ErrNoRows if there is none
+func (s *SQLStore) FindDatasetByName(ownerID int, name string) (*models.Dataset, error) {
+	d := &models.Dataset{}
+	err := scanDataset(d, s.db.QueryRow(`
+		SELECT `+datasetColumns+`
+		FROM datasets WHERE name = $1 AND deleted_at IS NULL AND ($2 = 0 OR owner_id = $2) ORDER BY id LIMIT 1
+	`, name, ownerID))
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ClaimUnownedDatasets makes ownerID the owner of all datasets created before datasets had owners
 // Returns an error on failure
-func DeleteDataset(db *sql.DB, id int) error {
-	_, err := db.Exec(`DELETE FROM datasets WHERE id = $1`, id)
+func (s *SQLStore) ClaimUnownedDatasets(ownerID int) error {
+	_, err := s.db.Exec(`UPDATE datasets SET owner_id = $1 WHERE owner_id IS NULL`, ownerID)
 	return err
 }
 
+// DeleteDataset moves a dataset with its entries into the trash and increments its version,
+// if version is set only if it still has that version
+// Returns ErrConflict if the version differs, or an error on failure
+func (s *SQLStore) DeleteDataset(id, version int) error {
+	res, err := s.db.Exec(`
+		UPDATE datasets SET deleted_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3)
+	`, s.dialect.storeTime(time.Now().UTC()), id, version)
+	if err != nil {
+		return err
+	}
+	return s.requireAffected(res, "datasets", id)
+}
+
 // CreateEntry creates a new entry in the database
 // Returns the ID of the new entry on success, or an error on failure
-func CreateEntry(db *sql.DB, e *models.Entry) (int, error) {
+func (s *SQLStore) CreateEntry(e *models.Entry) (int, error) {
 	var id int
-	err := db.QueryRow(`
+	err := s.db.QueryRow(`
 		INSERT INTO entries (dataset_id, value, label, date)
 		VALUES ($1, $2, $3, $4) RETURNING id
-	`, e.DatasetId, e.Value, e.Label, e.Date).Scan(&id)
+	`, e.DatasetId, e.Value, e.Label, s.dialect.storeTime(e.Date)).Scan(&id)
 	if err != nil {
 		utils.Error("Failed to create entry: " + err.Error())
 		return 0, err
@@ -93,24 +137,43 @@ func CreateEntry(db *sql.DB, e *models.Entry) (int, error) {
 	return id, nil
 }
 
-// UpdateEntry updates an entry in the database
-// Returns an error on failure
-func UpdateEntry(db *sql.DB, e *models.Entry) error {
-	_, err := db.Exec(`
+// GetEntry returns an entry from the database by ID, unless it is in the trash
+// Returns the entry on success or an error on failure
+func (s *SQLStore) GetEntry(id int) (*models.Entry, error) {
+	e := &models.Entry{}
+	err := scanEntry(e, s.db.QueryRow(`
+		SELECT `+entryColumns+`
+		FROM entries WHERE id = $1 AND deleted_at IS NULL
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// UpdateEntry updates an entry in the database and increments its version.
+// If e.Version is set, the entry is only updated if it still has that version.
+// Returns ErrConflict if the version differs, or an error on failure
+func (s *SQLStore) UpdateEntry(e *models.Entry) error {
+	res, err := s.db.Exec(`
 		UPDATE entries
-		SET value = $1, label = $2, date = $3
-		WHERE id = $4
-	`, e.Value, e.Label, e.Date, e.Id)
-	return err
+		SET value = $1, label = $2, date = $3, version = version + 1
+		WHERE id = $4 AND deleted_at IS NULL AND ($5 = 0 OR version = $5)
+	`, e.Value, e.Label, s.dialect.storeTime(e.Date), e.Id, e.Version)
+	if err != nil {
+		return err
+	}
+	return s.requireAffected(res, "entries", e.Id)
 }
 
 // ListEntriesByDataset returns a list of entries in a dataset
 // Returns a list of entries on success or an error on failure
-func ListEntriesByDataset(db *sql.DB, datasetID int) ([]models.Entry, error) {
-	rows, err := db.Query(`
-		SELECT id, dataset_id, value, label, date
+func (s *SQLStore) ListEntriesByDataset(datasetID int) ([]models.Entry, error) {
+	rows, err := s.db.Query(`
+		SELECT `+entryColumns+`
 		FROM entries
-		WHERE dataset_id = $1
+		WHERE dataset_id = $1 AND deleted_at IS NULL
+		ORDER BY date, id
 	`, datasetID)
 	if err != nil {
 		return nil, err
@@ -125,7 +188,7 @@ func ListEntriesByDataset(db *sql.DB, datasetID int) ([]models.Entry, error) {
 	var entries []models.Entry
 	for rows.Next() {
 		var e models.Entry
-		if err := rows.Scan(&e.Id, &e.DatasetId, &e.Value, &e.Label, &e.Date); err != nil {
+		if err := scanEntry(&e, rows); err != nil {
 			return nil, err
 		}
 		entries = append(entries, e)
@@ -133,9 +196,220 @@ func ListEntriesByDataset(db *sql.DB, datasetID int) ([]models.Entry, error) {
 	return entries, nil
 }
 
-// DeleteEntry deletes an entry from the database by ID
+// entrySortColumns maps the supported sort keys of entry listings to their column
+var entrySortColumns = map[string]string{
+	"date":  "date",
+	"value": "value",
+}
+
+// ValidEntrySort reports whether sort is a supported sort key for entry listings
+func ValidEntrySort(sort string) bool {
+	_, ok := entrySortColumns[sort]
+	return ok
+}
+
+// ListEntries returns a filtered, sorted page of the entries in a dataset.
+// With filter.Cumulative, values are running totals over the whole dataset before filtering.
+// Returns the page and the number of entries matching the filter on success or an error on failure
+func (s *SQLStore) ListEntries(datasetID int, filter models.EntryFilter) ([]models.Entry, int, error) {
+	sortColumn, ok := entrySortColumns[filter.Sort]
+	if !ok {
+		sortColumn = entrySortColumns["date"]
+	}
+	direction := "ASC"
+	if filter.Desc {
+		direction = "DESC"
+	}
+
+	valueColumn := "value"
+	if filter.Cumulative {
+		valueColumn = "SUM(value) OVER (ORDER BY date, id)"
+	}
+
+	args := []any{datasetID}
+	var conditions []string
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.From != nil {
+		addCondition("date >= $%d", s.dialect.storeTime(*filter.From))
+	}
+	if filter.To != nil {
+		addCondition("date <= $%d", s.dialect.storeTime(*filter.To))
+	}
+	if filter.Label != "" {
+		addCondition(`label `+s.dialect.caseInsensitiveLike+` '%%' || $%d || '%%' ESCAPE '\'`, escapeLike(filter.Label))
+	}
+	if filter.MinValue != nil {
+		addCondition("value >= $%d", *filter.MinValue)
+	}
+	if filter.MaxValue != nil {
+		addCondition("value <= $%d", *filter.MaxValue)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	from := fmt.Sprintf(`
+		FROM (
+			SELECT id, dataset_id, %s AS value, label, date, version, deleted_at
+			FROM entries
+			WHERE dataset_id = $1 AND deleted_at IS NULL
+		) e
+		%s
+	`, valueColumn, where)
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) `+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s %s ORDER BY %s %s, id %s`, entryColumns, from, sortColumn, direction, direction)
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		if filter.Limit <= 0 {
+			query += " LIMIT " + s.dialect.noLimit
+		}
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			utils.Error(err.Error())
+		}
+	}(rows)
+
+	entries := []models.Entry{}
+	for rows.Next() {
+		var e models.Entry
+		if err := scanEntry(&e, rows); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in s, so it is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// DeleteEntriesByDataset permanently deletes all entries of a dataset, including those in the trash
 // Returns an error on failure
-func DeleteEntry(db *sql.DB, id int) error {
-	_, err := db.Exec(`DELETE FROM entries WHERE id = $1`, id)
+func (s *SQLStore) DeleteEntriesByDataset(datasetID int) error {
+	_, err := s.db.Exec(`DELETE FROM entries WHERE dataset_id = $1`, datasetID)
+	return err
+}
+
+// DeleteEntry moves an entry into the trash and increments its version, if version is set only if it still has that version
+// Returns ErrConflict if the version differs, or an error on failure
+func (s *SQLStore) DeleteEntry(id, version int) error {
+	res, err := s.db.Exec(`
+		UPDATE entries SET deleted_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3)
+	`, s.dialect.storeTime(time.Now().UTC()), id, version)
+	if err != nil {
+		return err
+	}
+	return s.requireAffected(res, "entries", id)
+}
+
+// UpdateDatasetEntry updates an entry of the given dataset like UpdateEntry,
+// returning ErrNotFound if the dataset has no such entry
+func (s *SQLStore) UpdateDatasetEntry(e *models.Entry) error {
+	res, err := s.db.Exec(`
+		UPDATE entries
+		SET value = $1, label = $2, date = $3, version = version + 1
+		WHERE id = $4 AND dataset_id = $5 AND deleted_at IS NULL AND ($6 = 0 OR version = $6)
+	`, e.Value, e.Label, s.dialect.storeTime(e.Date), e.Id, e.DatasetId, e.Version)
+	if err != nil {
+		return err
+	}
+	return s.requireDatasetEntryAffected(res, e.DatasetId, e.Id)
+}
+
+// DeleteDatasetEntry moves an entry of the given dataset into the trash like DeleteEntry,
+// returning ErrNotFound if the dataset has no such entry
+func (s *SQLStore) DeleteDatasetEntry(datasetID, id, version int) error {
+	res, err := s.db.Exec(`
+		UPDATE entries SET deleted_at = $1, version = version + 1
+		WHERE id = $2 AND dataset_id = $3 AND deleted_at IS NULL AND ($4 = 0 OR version = $4)
+	`, s.dialect.storeTime(time.Now().UTC()), id, datasetID, version)
+	if err != nil {
+		return err
+	}
+	return s.requireDatasetEntryAffected(res, datasetID, id)
+}
+
+// requireAffected returns ErrNotFound if a statement did not affect the row with the given ID of table
+// because it does not exist or is in the trash, or ErrConflict if the row exists but its version did not match
+func (s *SQLStore) requireAffected(res sql.Result, table string, id int) error {
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+	var exists int
+	err = s.db.QueryRow(`SELECT 1 FROM `+table+` WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	return ErrConflict
+}
+
+// requireDatasetEntryAffected is requireAffected for an entry that also has to belong to the dataset
+func (s *SQLStore) requireDatasetEntryAffected(res sql.Result, datasetID, id int) error {
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+	var exists int
+	err = s.db.QueryRow(`SELECT 1 FROM entries WHERE id = $1 AND dataset_id = $2 AND deleted_at IS NULL`, id, datasetID).
+		Scan(&exists)
+	if err != nil {
+		return err
+	}
+	return ErrConflict
+}
+
+// optionalID converts an optional reference, 0 meaning none, for a nullable foreign key column
+func optionalID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// datasetColumns are the columns scanDataset reads, in order
+const datasetColumns = "id, name, description, symbol, target_value, start_date, end_date, kind, version, owner_id, deleted_at"
+
+func scanDataset(d *models.Dataset, row rowScanner) error {
+	var ownerID sql.NullInt64
+	err := row.Scan(&d.Id, &d.Name, &d.Description, &d.Symbol, &d.TargetValue, &d.StartDate, &d.EndDate, &d.Kind,
+		&d.Version, &ownerID, &d.DeletedAt)
+	d.OwnerId = int(ownerID.Int64)
 	return err
 }
+
+// entryColumns are the columns scanEntry reads, in order
+const entryColumns = "id, dataset_id, value, label, date, version, deleted_at"
+
+func scanEntry(e *models.Entry, row rowScanner) error {
+	return row.Scan(&e.Id, &e.DatasetId, &e.Value, &e.Label, &e.Date, &e.Version, &e.DeletedAt)
+}
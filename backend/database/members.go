@@ -0,0 +1,84 @@
+package database
+
+import (
+	"backend/models"
+	"backend/utils"
+	"database/sql"
+)
+
+// ListDatasetMembers returns the members of a dataset ordered by username
+// Returns a list of members on success or an error on failure
+func (s *SQLStore) ListDatasetMembers(datasetID int) ([]models.DatasetMember, error) {
+	rows, err := s.db.Query(`
+		SELECT `+memberColumns+`
+		FROM dataset_members m JOIN users u ON u.id = m.user_id
+		WHERE m.dataset_id = $1
+		ORDER BY u.username
+	`, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			utils.Error(err.Error())
+		}
+	}(rows)
+
+	members := []models.DatasetMember{}
+	for rows.Next() {
+		var m models.DatasetMember
+		if err := scanMember(&m, rows); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// GetDatasetMember returns the membership of a user in a dataset
+// Returns the member on success or sql.ErrNoRows if the user is no member
+func (s *SQLStore) GetDatasetMember(datasetID, userID int) (*models.DatasetMember, error) {
+	m := &models.DatasetMember{}
+	err := scanMember(m, s.db.QueryRow(`
+		SELECT `+memberColumns+`
+		FROM dataset_members m JOIN users u ON u.id = m.user_id
+		WHERE m.dataset_id = $1 AND m.user_id = $2
+	`, datasetID, userID))
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetDatasetMember adds a user to a dataset or changes the role of an existing member
+// Returns an error on failure
+func (s *SQLStore) SetDatasetMember(m *models.DatasetMember) error {
+	_, err := s.db.Exec(`
+		INSERT INTO dataset_members (dataset_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (dataset_id, user_id) DO UPDATE SET role = excluded.role
+	`, m.DatasetId, m.UserId, m.Role)
+	return err
+}
+
+// DeleteDatasetMember removes a user from a dataset
+// Returns sql.ErrNoRows if the user is no member, or an error on failure
+func (s *SQLStore) DeleteDatasetMember(datasetID, userID int) error {
+	res, err := s.db.Exec(`DELETE FROM dataset_members WHERE dataset_id = $1 AND user_id = $2`, datasetID, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// memberColumns are the columns scanMember reads, in order
+const memberColumns = "m.dataset_id, m.user_id, u.username, m.role"
+
+func scanMember(m *models.DatasetMember, row rowScanner) error {
+	return row.Scan(&m.DatasetId, &m.UserId, &m.Username, &m.Role)
+}
@@ -0,0 +1,830 @@
+package database
+
+import (
+	"backend/models"
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store keeping all data in memory, for tests and short-lived instances.
+// Times are stored as wall-clock times in UTC like in the SQL stores.
+type MemoryStore struct {
+	mu   *sync.Mutex
+	data *memoryData
+	// inTx is set on the store passed to InTransaction, which already holds mu
+	inTx bool
+}
+
+// memoryData holds the rows of a MemoryStore, copied at the start of a transaction
+type memoryData struct {
+	datasets map[int]models.Dataset
+	entries  map[int]models.Entry
+	users    map[int]models.User
+	sessions map[string]models.Session
+	members  map[memberKey]string
+	apiKeys  map[int]models.APIKey
+	auditLog []models.AuditEvent
+	// Datasets and entries in the trash are moved out of datasets and entries
+	trashedDatasets map[int]models.Dataset
+	trashedEntries  map[int]models.Entry
+	alertRules      map[int]models.AlertRule
+	alerts          []models.Alert
+	nextDatasetID   int
+	nextEntryID     int
+	nextUserID      int
+	nextAPIKeyID    int
+	nextAlertRuleID int
+	nextAlertID     int
+}
+
+// memberKey identifies the membership of a user in a dataset
+type memberKey struct {
+	datasetID, userID int
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu: &sync.Mutex{},
+		data: &memoryData{
+			datasets:        map[int]models.Dataset{},
+			entries:         map[int]models.Entry{},
+			users:           map[int]models.User{},
+			sessions:        map[string]models.Session{},
+			members:         map[memberKey]string{},
+			apiKeys:         map[int]models.APIKey{},
+			trashedDatasets: map[int]models.Dataset{},
+			trashedEntries:  map[int]models.Entry{},
+			alertRules:      map[int]models.AlertRule{},
+			nextDatasetID:   1,
+			nextEntryID:     1,
+			nextUserID:      1,
+			nextAPIKeyID:    1,
+			nextAlertRuleID: 1,
+			nextAlertID:     1,
+		},
+	}
+}
+
+// lock locks the store unless it is used inside a transaction, returning the matching unlock
+func (m *MemoryStore) lock() func() {
+	if m.inTx {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+func (m *MemoryStore) InTransaction(fn func(tx Store) error) error {
+	if m.inTx {
+		return fn(m)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &MemoryStore{mu: m.mu, data: m.data.clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	m.data = tx.data
+	return nil
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := *d
+	c.datasets = maps.Clone(d.datasets)
+	c.entries = maps.Clone(d.entries)
+	c.users = maps.Clone(d.users)
+	c.sessions = maps.Clone(d.sessions)
+	c.members = maps.Clone(d.members)
+	c.apiKeys = maps.Clone(d.apiKeys)
+	c.trashedDatasets = maps.Clone(d.trashedDatasets)
+	c.trashedEntries = maps.Clone(d.trashedEntries)
+	c.alertRules = maps.Clone(d.alertRules)
+	// Alerts are only appended or replaced as a whole
+	c.alerts = slices.Clip(d.alerts)
+	// Events are only appended, so the transaction only needs its own backing array to append to
+	c.auditLog = slices.Clip(d.auditLog)
+	return &c
+}
+
+func (m *MemoryStore) CreateDataset(d *models.Dataset) (int, error) {
+	defer m.lock()()
+	stored := storedDataset(*d)
+	stored.Id, stored.Version = m.data.nextDatasetID, 1
+	m.data.nextDatasetID++
+	m.data.datasets[stored.Id] = stored
+	return stored.Id, nil
+}
+
+func (m *MemoryStore) GetDataset(id int) (*models.Dataset, error) {
+	defer m.lock()()
+	d, ok := m.data.datasets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &d, nil
+}
+
+func (m *MemoryStore) FindDatasetByName(ownerID int, name string) (*models.Dataset, error) {
+	defer m.lock()()
+	for _, d := range m.sortedDatasets(func(d models.Dataset) bool { return ownerID == 0 || d.OwnerId == ownerID }) {
+		if d.Name == name {
+			return &d, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) ListDatasets(userID int) ([]models.Dataset, error) {
+	defer m.lock()()
+	return m.sortedDatasets(func(d models.Dataset) bool {
+		_, member := m.data.members[memberKey{d.Id, userID}]
+		return userID == 0 || d.OwnerId == userID || member
+	}), nil
+}
+
+func (m *MemoryStore) ClaimUnownedDatasets(ownerID int) error {
+	defer m.lock()()
+	for _, datasets := range []map[int]models.Dataset{m.data.datasets, m.data.trashedDatasets} {
+		for id, d := range datasets {
+			if d.OwnerId == 0 {
+				d.OwnerId = ownerID
+				datasets[id] = d
+			}
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpdateDataset(d *models.Dataset) error {
+	defer m.lock()()
+	existing, ok := m.data.datasets[d.Id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := checkVersion(existing.Version, d.Version); err != nil {
+		return err
+	}
+	stored := storedDataset(*d)
+	stored.Version, stored.OwnerId = existing.Version+1, existing.OwnerId
+	m.data.datasets[d.Id] = stored
+	return nil
+}
+
+func (m *MemoryStore) DeleteDataset(id, version int) error {
+	defer m.lock()()
+	existing, ok := m.data.datasets[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := checkVersion(existing.Version, version); err != nil {
+		return err
+	}
+	delete(m.data.datasets, id)
+	existing.DeletedAt, existing.Version = trashedAt(), existing.Version+1
+	m.data.trashedDatasets[id] = existing
+	return nil
+}
+
+func (m *MemoryStore) CreateEntry(e *models.Entry) (int, error) {
+	defer m.lock()()
+	if _, ok := m.data.datasets[e.DatasetId]; !ok {
+		return 0, ErrNotFound
+	}
+	stored := storedEntry(*e)
+	stored.Id, stored.Version = m.data.nextEntryID, 1
+	m.data.nextEntryID++
+	m.data.entries[stored.Id] = stored
+	return stored.Id, nil
+}
+
+func (m *MemoryStore) ListEntriesByDataset(datasetID int) ([]models.Entry, error) {
+	defer m.lock()()
+	entries := m.datasetEntries(datasetID)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) ListEntries(datasetID int, filter models.EntryFilter) ([]models.Entry, int, error) {
+	defer m.lock()()
+	all := m.datasetEntries(datasetID)
+	if filter.Cumulative {
+		total := 0.0
+		for i := range all {
+			total += all[i].Value
+			all[i].Value = total
+		}
+	}
+
+	label := strings.ToLower(filter.Label)
+	entries := []models.Entry{}
+	for _, e := range all {
+		switch {
+		case filter.From != nil && e.Date.Before(wallClock(*filter.From)),
+			filter.To != nil && e.Date.After(wallClock(*filter.To)),
+			label != "" && !strings.Contains(strings.ToLower(e.Label), label),
+			filter.MinValue != nil && e.Value < *filter.MinValue,
+			filter.MaxValue != nil && e.Value > *filter.MaxValue:
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	slices.SortStableFunc(entries, func(a, b models.Entry) int {
+		c := a.Date.Compare(b.Date)
+		if filter.Sort == "value" {
+			c = cmp.Compare(a.Value, b.Value)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Id, b.Id)
+		}
+		if filter.Desc {
+			return -c
+		}
+		return c
+	})
+
+	total := len(entries)
+	entries = entries[min(filter.Offset, total):]
+	if filter.Limit > 0 && filter.Limit < len(entries) {
+		entries = entries[:filter.Limit]
+	}
+	return entries, total, nil
+}
+
+func (m *MemoryStore) AggregateEntries(datasetID int, bucket, fn string) ([]models.SeriesPoint, error) {
+	entries, err := m.ListEntriesByDataset(datasetID)
+	if err != nil {
+		return nil, err
+	}
+	return aggregateEntries(entries, bucket, fn), nil
+}
+
+func (m *MemoryStore) GetEntry(id int) (*models.Entry, error) {
+	defer m.lock()()
+	e, ok := m.data.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &e, nil
+}
+
+func (m *MemoryStore) UpdateEntry(e *models.Entry) error {
+	defer m.lock()()
+	existing, ok := m.data.entries[e.Id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := checkVersion(existing.Version, e.Version); err != nil {
+		return err
+	}
+	m.data.entries[e.Id] = updatedEntry(existing, *e)
+	return nil
+}
+
+func (m *MemoryStore) UpdateDatasetEntry(e *models.Entry) error {
+	defer m.lock()()
+	existing, ok := m.data.entries[e.Id]
+	if !ok || existing.DatasetId != e.DatasetId {
+		return ErrNotFound
+	}
+	if err := checkVersion(existing.Version, e.Version); err != nil {
+		return err
+	}
+	m.data.entries[e.Id] = updatedEntry(existing, *e)
+	return nil
+}
+
+func (m *MemoryStore) DeleteEntry(id, version int) error {
+	defer m.lock()()
+	existing, ok := m.data.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := checkVersion(existing.Version, version); err != nil {
+		return err
+	}
+	delete(m.data.entries, id)
+	existing.DeletedAt, existing.Version = trashedAt(), existing.Version+1
+	m.data.trashedEntries[id] = existing
+	return nil
+}
+
+func (m *MemoryStore) DeleteDatasetEntry(datasetID, id, version int) error {
+	defer m.lock()()
+	existing, ok := m.data.entries[id]
+	if !ok || existing.DatasetId != datasetID {
+		return ErrNotFound
+	}
+	if err := checkVersion(existing.Version, version); err != nil {
+		return err
+	}
+	delete(m.data.entries, id)
+	existing.DeletedAt, existing.Version = trashedAt(), existing.Version+1
+	m.data.trashedEntries[id] = existing
+	return nil
+}
+
+func (m *MemoryStore) DeleteEntriesByDataset(datasetID int) error {
+	defer m.lock()()
+	m.deleteEntriesWhere(func(e models.Entry) bool { return e.DatasetId == datasetID })
+	return nil
+}
+
+func (m *MemoryStore) ListTrash(userID int) (*models.Trash, error) {
+	defer m.lock()()
+	trash := &models.Trash{Datasets: []models.Dataset{}, Entries: []models.Entry{}}
+	for _, d := range m.data.trashedDatasets {
+		if userID == 0 || d.OwnerId == userID {
+			trash.Datasets = append(trash.Datasets, d)
+		}
+	}
+	for _, e := range m.data.trashedEntries {
+		d, ok := m.data.datasets[e.DatasetId]
+		_, member := m.data.members[memberKey{e.DatasetId, userID}]
+		if ok && (userID == 0 || d.OwnerId == userID || member) {
+			trash.Entries = append(trash.Entries, e)
+		}
+	}
+	slices.SortFunc(trash.Datasets, func(a, b models.Dataset) int {
+		return cmp.Or(b.DeletedAt.Compare(*a.DeletedAt), cmp.Compare(b.Id, a.Id))
+	})
+	slices.SortFunc(trash.Entries, func(a, b models.Entry) int {
+		return cmp.Or(b.DeletedAt.Compare(*a.DeletedAt), cmp.Compare(b.Id, a.Id))
+	})
+	return trash, nil
+}
+
+func (m *MemoryStore) GetTrashedDataset(id int) (*models.Dataset, error) {
+	defer m.lock()()
+	d, ok := m.data.trashedDatasets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &d, nil
+}
+
+func (m *MemoryStore) GetTrashedEntry(id int) (*models.Entry, error) {
+	defer m.lock()()
+	e, ok := m.data.trashedEntries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &e, nil
+}
+
+func (m *MemoryStore) RestoreDataset(id int) error {
+	defer m.lock()()
+	d, ok := m.data.trashedDatasets[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.data.trashedDatasets, id)
+	d.DeletedAt = nil
+	d.Version++
+	m.data.datasets[id] = d
+	return nil
+}
+
+func (m *MemoryStore) RestoreEntry(id int) error {
+	defer m.lock()()
+	e, ok := m.data.trashedEntries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.data.trashedEntries, id)
+	e.DeletedAt = nil
+	e.Version++
+	m.data.entries[id] = e
+	return nil
+}
+
+func (m *MemoryStore) PurgeTrash(before time.Time) (int, error) {
+	defer m.lock()()
+	before = wallClock(before.UTC())
+	purged := 0
+	for id, e := range m.data.trashedEntries {
+		if e.DeletedAt.Before(before) {
+			delete(m.data.trashedEntries, id)
+			purged++
+		}
+	}
+	for id, d := range m.data.trashedDatasets {
+		if !d.DeletedAt.Before(before) {
+			continue
+		}
+		delete(m.data.trashedDatasets, id)
+		purged++
+		purged += m.deleteEntriesWhere(func(e models.Entry) bool { return e.DatasetId == id })
+		for key := range m.data.members {
+			if key.datasetID == id {
+				delete(m.data.members, key)
+			}
+		}
+		for keyID, k := range m.data.apiKeys {
+			if k.DatasetId == id {
+				delete(m.data.apiKeys, keyID)
+			}
+		}
+		for ruleID, rule := range m.data.alertRules {
+			if rule.DatasetId == id {
+				delete(m.data.alertRules, ruleID)
+			}
+		}
+		var kept []models.Alert
+		for _, a := range m.data.alerts {
+			if a.DatasetId != id {
+				kept = append(kept, a)
+			}
+		}
+		m.data.alerts = kept
+	}
+	return purged, nil
+}
+
+func (m *MemoryStore) CreateUser(u *models.User) (int, error) {
+	defer m.lock()()
+	for _, existing := range m.data.users {
+		if existing.Username == u.Username {
+			return 0, ErrDuplicate
+		}
+	}
+	stored := *u
+	stored.Id, stored.CreatedAt = m.data.nextUserID, wallClock(u.CreatedAt.UTC())
+	m.data.nextUserID++
+	m.data.users[stored.Id] = stored
+	return stored.Id, nil
+}
+
+func (m *MemoryStore) GetUser(id int) (*models.User, error) {
+	defer m.lock()()
+	u, ok := m.data.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+func (m *MemoryStore) FindUserByUsername(username string) (*models.User, error) {
+	defer m.lock()()
+	for _, u := range m.data.users {
+		if u.Username == username {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) CountUsers() (int, error) {
+	defer m.lock()()
+	return len(m.data.users), nil
+}
+
+func (m *MemoryStore) CreateSession(session *models.Session) error {
+	defer m.lock()()
+	if _, ok := m.data.users[session.UserId]; !ok {
+		return ErrNotFound
+	}
+	stored := *session
+	stored.CreatedAt, stored.ExpiresAt = wallClock(session.CreatedAt.UTC()), wallClock(session.ExpiresAt.UTC())
+	m.data.sessions[session.TokenHash] = stored
+	return nil
+}
+
+func (m *MemoryStore) GetSession(tokenHash string) (*models.Session, error) {
+	defer m.lock()()
+	session, ok := m.data.sessions[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+func (m *MemoryStore) DeleteSession(tokenHash string) error {
+	defer m.lock()()
+	delete(m.data.sessions, tokenHash)
+	return nil
+}
+
+func (m *MemoryStore) DeleteExpiredSessions(now time.Time) error {
+	defer m.lock()()
+	for tokenHash, session := range m.data.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(m.data.sessions, tokenHash)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListDatasetMembers(datasetID int) ([]models.DatasetMember, error) {
+	defer m.lock()()
+	members := []models.DatasetMember{}
+	for key := range m.data.members {
+		if key.datasetID == datasetID {
+			members = append(members, m.member(key))
+		}
+	}
+	slices.SortFunc(members, func(a, b models.DatasetMember) int { return cmp.Compare(a.Username, b.Username) })
+	return members, nil
+}
+
+func (m *MemoryStore) GetDatasetMember(datasetID, userID int) (*models.DatasetMember, error) {
+	defer m.lock()()
+	key := memberKey{datasetID, userID}
+	if _, ok := m.data.members[key]; !ok {
+		return nil, ErrNotFound
+	}
+	member := m.member(key)
+	return &member, nil
+}
+
+func (m *MemoryStore) SetDatasetMember(member *models.DatasetMember) error {
+	defer m.lock()()
+	_, datasetExists := m.data.datasets[member.DatasetId]
+	_, userExists := m.data.users[member.UserId]
+	if !datasetExists || !userExists {
+		return ErrNotFound
+	}
+	m.data.members[memberKey{member.DatasetId, member.UserId}] = member.Role
+	return nil
+}
+
+func (m *MemoryStore) DeleteDatasetMember(datasetID, userID int) error {
+	defer m.lock()()
+	key := memberKey{datasetID, userID}
+	if _, ok := m.data.members[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.data.members, key)
+	return nil
+}
+
+func (m *MemoryStore) CreateAPIKey(k *models.APIKey) (int, error) {
+	defer m.lock()()
+	if _, ok := m.data.datasets[k.DatasetId]; !ok {
+		return 0, ErrNotFound
+	}
+	stored := *k
+	stored.Id, stored.CreatedAt = m.data.nextAPIKeyID, wallClock(k.CreatedAt.UTC())
+	m.data.nextAPIKeyID++
+	m.data.apiKeys[stored.Id] = stored
+	return stored.Id, nil
+}
+
+func (m *MemoryStore) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	defer m.lock()()
+	for _, k := range m.data.apiKeys {
+		if k.KeyHash == keyHash {
+			return &k, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) ListAPIKeys(datasetID int) ([]models.APIKey, error) {
+	defer m.lock()()
+	keys := []models.APIKey{}
+	for _, k := range m.data.apiKeys {
+		if k.DatasetId == datasetID {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortFunc(keys, func(a, b models.APIKey) int { return cmp.Compare(a.Id, b.Id) })
+	return keys, nil
+}
+
+func (m *MemoryStore) RevokeAPIKey(datasetID, id int, now time.Time) error {
+	defer m.lock()()
+	k, ok := m.data.apiKeys[id]
+	if !ok || k.DatasetId != datasetID || k.RevokedAt != nil {
+		return ErrNotFound
+	}
+	revokedAt := wallClock(now.UTC())
+	k.RevokedAt = &revokedAt
+	m.data.apiKeys[id] = k
+	return nil
+}
+
+func (m *MemoryStore) TouchAPIKey(id int, now time.Time) error {
+	defer m.lock()()
+	if k, ok := m.data.apiKeys[id]; ok {
+		lastUsedAt := wallClock(now.UTC())
+		k.LastUsedAt = &lastUsedAt
+		m.data.apiKeys[id] = k
+	}
+	return nil
+}
+
+func (m *MemoryStore) AppendAuditEvent(e *models.AuditEvent) error {
+	defer m.lock()()
+	stored := *e
+	stored.Id, stored.CreatedAt = len(m.data.auditLog)+1, wallClock(e.CreatedAt.UTC())
+	m.data.auditLog = append(m.data.auditLog, stored)
+	return nil
+}
+
+func (m *MemoryStore) ListAuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	defer m.lock()()
+	events := []models.AuditEvent{}
+	for _, e := range slices.Backward(m.data.auditLog) {
+		if filter.EntryId != 0 && e.EntryId == filter.EntryId || filter.EntryId == 0 && e.DatasetId == filter.DatasetId {
+			events = append(events, e)
+		}
+	}
+	events = events[min(filter.Offset, len(events)):]
+	if filter.Limit > 0 && filter.Limit < len(events) {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+func (m *MemoryStore) CreateAlertRule(rule *models.AlertRule) (int, error) {
+	defer m.lock()()
+	if _, ok := m.data.datasets[rule.DatasetId]; !ok {
+		return 0, ErrNotFound
+	}
+	stored := *rule
+	stored.Id, stored.CreatedAt = m.data.nextAlertRuleID, wallClock(rule.CreatedAt.UTC())
+	stored.Firing, stored.LastTriggeredAt = false, nil
+	if rule.Threshold != nil {
+		threshold := *rule.Threshold
+		stored.Threshold = &threshold
+	}
+	m.data.nextAlertRuleID++
+	m.data.alertRules[stored.Id] = stored
+	return stored.Id, nil
+}
+
+func (m *MemoryStore) ListAlertRules(datasetID int) ([]models.AlertRule, error) {
+	defer m.lock()()
+	rules := []models.AlertRule{}
+	for _, rule := range m.data.alertRules {
+		_, active := m.data.datasets[rule.DatasetId]
+		if rule.DatasetId == datasetID || datasetID == 0 && active {
+			rules = append(rules, rule)
+		}
+	}
+	slices.SortFunc(rules, func(a, b models.AlertRule) int {
+		return cmp.Or(cmp.Compare(a.DatasetId, b.DatasetId), cmp.Compare(a.Id, b.Id))
+	})
+	return rules, nil
+}
+
+func (m *MemoryStore) DeleteAlertRule(datasetID, id int) error {
+	defer m.lock()()
+	rule, ok := m.data.alertRules[id]
+	if !ok || rule.DatasetId != datasetID {
+		return ErrNotFound
+	}
+	delete(m.data.alertRules, id)
+	return nil
+}
+
+func (m *MemoryStore) SetAlertRuleFiring(id int, firing bool, triggeredAt *time.Time) error {
+	defer m.lock()()
+	rule, ok := m.data.alertRules[id]
+	if !ok {
+		return nil
+	}
+	rule.Firing = firing
+	if triggeredAt != nil {
+		triggered := wallClock(triggeredAt.UTC())
+		rule.LastTriggeredAt = &triggered
+	}
+	m.data.alertRules[id] = rule
+	return nil
+}
+
+func (m *MemoryStore) CreateAlert(a *models.Alert) (int, error) {
+	defer m.lock()()
+	stored := *a
+	stored.Id, stored.CreatedAt = m.data.nextAlertID, wallClock(a.CreatedAt.UTC())
+	m.data.nextAlertID++
+	m.data.alerts = append(m.data.alerts, stored)
+	return stored.Id, nil
+}
+
+func (m *MemoryStore) ListAlerts(filter models.AlertFilter) ([]models.Alert, error) {
+	defer m.lock()()
+	alerts := []models.Alert{}
+	for _, a := range slices.Backward(m.data.alerts) {
+		if a.DatasetId == filter.DatasetId && (filter.RuleId == 0 || a.RuleId == filter.RuleId) {
+			alerts = append(alerts, a)
+		}
+	}
+	alerts = alerts[min(filter.Offset, len(alerts)):]
+	if filter.Limit > 0 && filter.Limit < len(alerts) {
+		alerts = alerts[:filter.Limit]
+	}
+	return alerts, nil
+}
+
+// member returns the membership stored under key
+func (m *MemoryStore) member(key memberKey) models.DatasetMember {
+	return models.DatasetMember{
+		DatasetId: key.datasetID,
+		UserId:    key.userID,
+		Username:  m.data.users[key.userID].Username,
+		Role:      m.data.members[key],
+	}
+}
+
+// sortedDatasets returns the datasets matching match ordered by ID
+func (m *MemoryStore) sortedDatasets(match func(d models.Dataset) bool) []models.Dataset {
+	var datasets []models.Dataset
+	for _, d := range m.data.datasets {
+		if match(d) {
+			datasets = append(datasets, d)
+		}
+	}
+	slices.SortFunc(datasets, func(a, b models.Dataset) int { return cmp.Compare(a.Id, b.Id) })
+	return datasets
+}
+
+// datasetEntries returns the entries of a dataset ordered by date and ID
+func (m *MemoryStore) datasetEntries(datasetID int) []models.Entry {
+	var entries []models.Entry
+	for _, e := range m.data.entries {
+		if e.DatasetId == datasetID {
+			entries = append(entries, e)
+		}
+	}
+	slices.SortFunc(entries, func(a, b models.Entry) int {
+		if c := a.Date.Compare(b.Date); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return entries
+}
+
+// deleteEntriesWhere permanently deletes the entries matching match, including those in the trash, and returns how many it deleted
+func (m *MemoryStore) deleteEntriesWhere(match func(e models.Entry) bool) int {
+	deleted := 0
+	for id, e := range m.data.entries {
+		if match(e) {
+			delete(m.data.entries, id)
+			deleted++
+		}
+	}
+	for id, e := range m.data.trashedEntries {
+		if match(e) {
+			delete(m.data.trashedEntries, id)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// trashedAt returns the time a dataset or entry moved into the trash now is stored with
+func trashedAt() *time.Time {
+	now := wallClock(time.Now().UTC())
+	return &now
+}
+
+// updatedEntry applies the columns an update writes to an existing entry and increments its version
+func updatedEntry(existing, e models.Entry) models.Entry {
+	existing.Value = e.Value
+	existing.Label = e.Label
+	existing.Date = wallClock(e.Date)
+	existing.Version++
+	return existing
+}
+
+// checkVersion returns ErrConflict if an expected version is given and differs from the current one
+func checkVersion(current, expected int) error {
+	if expected != 0 && expected != current {
+		return ErrConflict
+	}
+	return nil
+}
+
+// storedDataset returns the dataset as a SQL store would read it back
+func storedDataset(d models.Dataset) models.Dataset {
+	if d.StartDate != nil {
+		start := wallClock(*d.StartDate)
+		d.StartDate = &start
+	}
+	if d.EndDate != nil {
+		end := wallClock(*d.EndDate)
+		d.EndDate = &end
+	}
+	if d.TargetValue != nil {
+		target := *d.TargetValue
+		d.TargetValue = &target
+	}
+	d.DeletedAt = nil
+	return d
+}
+
+// storedEntry returns the entry as a SQL store would read it back
+func storedEntry(e models.Entry) models.Entry {
+	return models.Entry{Id: e.Id, DatasetId: e.DatasetId, Value: e.Value, Label: e.Label, Date: wallClock(e.Date), Version: e.Version}
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"backend/models"
+	"backend/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Storage backends selectable with the STORAGE_BACKEND environment variable
+const (
+	BackendPostgres = "postgres"
+	BackendSQLite   = "sqlite"
+	BackendMemory   = "memory"
+)
+
+// ErrNotFound is returned when a dataset or entry does not exist.
+// It is sql.ErrNoRows, so errors of the SQL stores match it without wrapping.
+var ErrNotFound = sql.ErrNoRows
+
+// ErrConflict is returned when a dataset or entry is updated or deleted with a version it no longer has
+var ErrConflict = errors.New("version conflict")
+
+// ErrDuplicate is returned when a user is created with a username that is already taken
+var ErrDuplicate = errors.New("already exists")
+
+// Store persists datasets and their entries.
+// Getting, updating or deleting a dataset or entry that does not exist or is in the trash returns ErrNotFound.
+// Datasets and entries carry a version incremented by every update. Updates and deletes given a
+// version other than 0 only apply if the row still has it and return ErrConflict otherwise.
+// Finding datasets is limited to those of an owner and listing them to those a user owns or is a member of,
+// user 0 matches all datasets.
+type Store interface {
+	CreateDataset(d *models.Dataset) (int, error)
+	GetDataset(id int) (*models.Dataset, error)
+	FindDatasetByName(ownerID int, name string) (*models.Dataset, error)
+	ListDatasets(userID int) ([]models.Dataset, error)
+	// ClaimUnownedDatasets makes ownerID the owner of all datasets without one
+	ClaimUnownedDatasets(ownerID int) error
+	UpdateDataset(d *models.Dataset) error
+	DeleteDataset(id, version int) error
+
+	CreateEntry(e *models.Entry) (int, error)
+	GetEntry(id int) (*models.Entry, error)
+	ListEntriesByDataset(datasetID int) ([]models.Entry, error)
+	ListEntries(datasetID int, filter models.EntryFilter) ([]models.Entry, int, error)
+	AggregateEntries(datasetID int, bucket, fn string) ([]models.SeriesPoint, error)
+	UpdateEntry(e *models.Entry) error
+	UpdateDatasetEntry(e *models.Entry) error
+	DeleteEntry(id, version int) error
+	DeleteDatasetEntry(datasetID, id, version int) error
+	DeleteEntriesByDataset(datasetID int) error
+
+	// Deleted datasets and entries are kept in the trash until they are restored or purged.
+	// The entries of a dataset stay where they are when it is moved into the trash, so restoring it
+	// brings back all entries that were not deleted on their own.
+	ListTrash(userID int) (*models.Trash, error)
+	GetTrashedDataset(id int) (*models.Dataset, error)
+	GetTrashedEntry(id int) (*models.Entry, error)
+	RestoreDataset(id int) error
+	RestoreEntry(id int) error
+	// PurgeTrash permanently deletes what was moved into the trash before the given time,
+	// returning how many datasets and entries it deleted, including the entries of purged datasets
+	PurgeTrash(before time.Time) (int, error)
+
+	// Members are the users a dataset is shared with besides its owner
+	ListDatasetMembers(datasetID int) ([]models.DatasetMember, error)
+	GetDatasetMember(datasetID, userID int) (*models.DatasetMember, error)
+	// SetDatasetMember adds a member or changes the role of an existing one
+	SetDatasetMember(m *models.DatasetMember) error
+	DeleteDatasetMember(datasetID, userID int) error
+
+	CreateAPIKey(k *models.APIKey) (int, error)
+	GetAPIKeyByHash(keyHash string) (*models.APIKey, error)
+	ListAPIKeys(datasetID int) ([]models.APIKey, error)
+	// RevokeAPIKey marks a key of a dataset as revoked, returning ErrNotFound if it does not exist or is revoked already
+	RevokeAPIKey(datasetID, id int, now time.Time) error
+	// TouchAPIKey records that a key was used at now
+	TouchAPIKey(id int, now time.Time) error
+
+	// The audit log is append-only, events are never changed or deleted
+	AppendAuditEvent(e *models.AuditEvent) error
+	// ListAuditEvents returns the events of a dataset or an entry, newest first
+	ListAuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error)
+
+	CreateAlertRule(rule *models.AlertRule) (int, error)
+	// ListAlertRules returns the rules of a dataset, or of all datasets not in the trash if datasetID is 0
+	ListAlertRules(datasetID int) ([]models.AlertRule, error)
+	DeleteAlertRule(datasetID, id int) error
+	// SetAlertRuleFiring records whether the condition of a rule holds, and when it was triggered if triggeredAt is set
+	SetAlertRuleFiring(id int, firing bool, triggeredAt *time.Time) error
+	CreateAlert(a *models.Alert) (int, error)
+	// ListAlerts returns the alerts raised for a dataset, only those of one rule if filter.RuleId is set, newest first
+	ListAlerts(filter models.AlertFilter) ([]models.Alert, error)
+
+	CreateUser(u *models.User) (int, error)
+	GetUser(id int) (*models.User, error)
+	FindUserByUsername(username string) (*models.User, error)
+	CountUsers() (int, error)
+
+	CreateSession(session *models.Session) error
+	GetSession(tokenHash string) (*models.Session, error)
+	DeleteSession(tokenHash string) error
+	DeleteExpiredSessions(now time.Time) error
+
+	// InTransaction runs fn with a store whose changes are committed if fn succeeds and discarded otherwise.
+	// Calling it on the store passed to fn runs the nested function in the same transaction.
+	InTransaction(fn func(tx Store) error) error
+}
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so SQLStore can run inside a transaction
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// dialect holds what differs between the SQL databases SQLStore supports
+type dialect struct {
+	name string
+	// caseInsensitiveLike is the operator matching a LIKE pattern regardless of case
+	caseInsensitiveLike string
+	// noLimit is the LIMIT needed to use OFFSET without limiting the number of rows
+	noLimit string
+	// storeTime converts a time into the value written to and compared with date columns
+	storeTime func(t time.Time) any
+	// uniqueViolation reports whether err was caused by a violated UNIQUE constraint
+	uniqueViolation func(err error) bool
+}
+
+var dialects = map[string]dialect{
+	BackendPostgres: {
+		name:                BackendPostgres,
+		caseInsensitiveLike: "ILIKE",
+		noLimit:             "ALL",
+		// TIMESTAMP columns ignore the offset and keep the wall-clock time
+		storeTime: func(t time.Time) any { return t },
+		uniqueViolation: func(err error) bool {
+			var pqErr *pq.Error
+			return errors.As(err, &pqErr) && pqErr.Code == "23505"
+		},
+	},
+	BackendSQLite: {
+		name:                BackendSQLite,
+		caseInsensitiveLike: "LIKE",
+		noLimit:             "-1",
+		// Times are stored as text, so they are written in one offset to compare and sort correctly.
+		// Like Postgres, the wall-clock time is kept.
+		storeTime: func(t time.Time) any { return wallClock(t) },
+		uniqueViolation: func(err error) bool {
+			var sqliteErr *sqlite.Error
+			return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+		},
+	},
+}
+
+// SQLStore is a Store backed by Postgres or SQLite
+type SQLStore struct {
+	db      DBTX
+	conn    *sql.DB
+	dialect dialect
+}
+
+// NewSQLStore returns a store using conn, backend is BackendPostgres or BackendSQLite
+func NewSQLStore(conn *sql.DB, backend string) (*SQLStore, error) {
+	d, ok := dialects[backend]
+	if !ok {
+		return nil, fmt.Errorf("unsupported SQL backend %q", backend)
+	}
+	return &SQLStore{db: conn, conn: conn, dialect: d}, nil
+}
+
+// DB returns the underlying connection pool
+func (s *SQLStore) DB() *sql.DB {
+	return s.conn
+}
+
+// Backend returns BackendPostgres or BackendSQLite
+func (s *SQLStore) Backend() string {
+	return s.dialect.name
+}
+
+func (s *SQLStore) InTransaction(fn func(tx Store) error) error {
+	if _, nested := s.db.(*sql.Tx); nested {
+		return fn(s)
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(&SQLStore{db: tx, conn: s.conn, dialect: s.dialect}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			utils.Error("failed to roll back transaction: " + rbErr.Error())
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// optionalTime converts an optional time for a date column
+func (s *SQLStore) optionalTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return s.dialect.storeTime(*t)
+}
+
+// wallClock returns the wall-clock time of t in UTC, dropping its offset like a Postgres TIMESTAMP column
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
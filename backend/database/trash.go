@@ -0,0 +1,158 @@
+package database
+
+import (
+	"backend/models"
+	"backend/utils"
+	"database/sql"
+	"time"
+)
+
+// ListTrash returns the deleted datasets a user owns and the deleted entries of the datasets they own
+// or are a member of, newest first. User 0 gets the whole trash.
+// Returns the trash on success or an error on failure
+func (s *SQLStore) ListTrash(userID int) (*models.Trash, error) {
+	trash := &models.Trash{Datasets: []models.Dataset{}, Entries: []models.Entry{}}
+
+	rows, err := s.db.Query(`
+		SELECT `+datasetColumns+`
+		FROM datasets
+		WHERE deleted_at IS NOT NULL AND ($1 = 0 OR owner_id = $1)
+		ORDER BY deleted_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			utils.Error(err.Error())
+		}
+	}(rows)
+	for rows.Next() {
+		var d models.Dataset
+		if err := scanDataset(&d, rows); err != nil {
+			return nil, err
+		}
+		trash.Datasets = append(trash.Datasets, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	entryRows, err := s.db.Query(`
+		SELECT `+entryColumns+`
+		FROM entries
+		WHERE deleted_at IS NOT NULL AND dataset_id IN (
+			SELECT id FROM datasets
+			WHERE deleted_at IS NULL
+			  AND ($1 = 0 OR owner_id = $1 OR id IN (SELECT dataset_id FROM dataset_members WHERE user_id = $1))
+		)
+		ORDER BY deleted_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			utils.Error(err.Error())
+		}
+	}(entryRows)
+	for entryRows.Next() {
+		var e models.Entry
+		if err := scanEntry(&e, entryRows); err != nil {
+			return nil, err
+		}
+		trash.Entries = append(trash.Entries, e)
+	}
+	return trash, entryRows.Err()
+}
+
+// GetTrashedDataset returns a dataset in the trash by ID
+// Returns the dataset on success or sql.ErrNoRows if the trash has no such dataset
+func (s *SQLStore) GetTrashedDataset(id int) (*models.Dataset, error) {
+	d := &models.Dataset{}
+	err := scanDataset(d, s.db.QueryRow(`
+		SELECT `+datasetColumns+`
+		FROM datasets WHERE id = $1 AND deleted_at IS NOT NULL
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetTrashedEntry returns an entry in the trash by ID
+// Returns the entry on success or sql.ErrNoRows if the trash has no such entry
+func (s *SQLStore) GetTrashedEntry(id int) (*models.Entry, error) {
+	e := &models.Entry{}
+	err := scanEntry(e, s.db.QueryRow(`
+		SELECT `+entryColumns+`
+		FROM entries WHERE id = $1 AND deleted_at IS NOT NULL
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// RestoreDataset takes a dataset with its entries out of the trash and increments its version
+// Returns sql.ErrNoRows if the trash has no such dataset, or an error on failure
+func (s *SQLStore) RestoreDataset(id int) error {
+	res, err := s.db.Exec(`
+		UPDATE datasets SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	return requireRestored(res)
+}
+
+// RestoreEntry takes an entry out of the trash and increments its version
+// Returns sql.ErrNoRows if the trash has no such entry, or an error on failure
+func (s *SQLStore) RestoreEntry(id int) error {
+	res, err := s.db.Exec(`
+		UPDATE entries SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	return requireRestored(res)
+}
+
+// PurgeTrash permanently deletes the datasets and entries moved into the trash before the given time,
+// a purged dataset together with its entries, members and API keys.
+// The entries of purged datasets are deleted explicitly rather than by cascade, so they are counted as well.
+// Returns the number of purged datasets and entries on success or an error on failure
+func (s *SQLStore) PurgeTrash(before time.Time) (int, error) {
+	purged := 0
+	for _, query := range []string{
+		`DELETE FROM entries WHERE deleted_at < $1 OR dataset_id IN (SELECT id FROM datasets WHERE deleted_at < $1)`,
+		`DELETE FROM datasets WHERE deleted_at < $1`,
+	} {
+		res, err := s.db.Exec(query, s.dialect.storeTime(before.UTC()))
+		if err != nil {
+			return purged, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return purged, err
+		}
+		purged += int(n)
+	}
+	return purged, nil
+}
+
+// requireRestored returns ErrNotFound if a restore did not affect any row
+func requireRestored(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package database_test
+
+import (
+	"backend/database"
+	"backend/models"
+	"testing"
+	"time"
+)
+
+func TestPurgeTrashCountsAndAuditsPurgedRows(t *testing.T) {
+	for backend, store := range testStores(t) {
+		t.Run(backend, func(t *testing.T) {
+			createDataset := func(entries int) (int, []int) {
+				datasetID, err := store.CreateDataset(&models.Dataset{Name: "purged", Kind: models.KindCumulative})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var entryIDs []int
+				for i := range entries {
+					id, err := store.CreateEntry(&models.Entry{DatasetId: datasetID, Value: float64(i), Date: time.Now()})
+					if err != nil {
+						t.Fatal(err)
+					}
+					entryIDs = append(entryIDs, id)
+				}
+				return datasetID, entryIDs
+			}
+
+			trashedDataset, _ := createDataset(2)
+			if err := store.DeleteDataset(trashedDataset, 0); err != nil {
+				t.Fatal(err)
+			}
+			kept, keptEntries := createDataset(2)
+			if err := store.DeleteEntry(keptEntries[0], 0); err != nil {
+				t.Fatal(err)
+			}
+
+			audited := database.NewAuditedStore(store, models.Actor{Name: "trash purge"})
+			purged, err := audited.PurgeTrash(time.Now().Add(time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The trashed dataset, its two entries and the trashed entry of the kept dataset
+			if purged != 4 {
+				t.Errorf("purged %d, want 4", purged)
+			}
+
+			for _, want := range []models.AuditEvent{
+				{DatasetId: trashedDataset, Entity: models.AuditDataset},
+				{DatasetId: kept, EntryId: keptEntries[0], Entity: models.AuditEntry},
+			} {
+				events, err := store.ListAuditEvents(models.AuditFilter{DatasetId: want.DatasetId})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(events) != 1 {
+					t.Fatalf("got %d events for dataset %d, want 1", len(events), want.DatasetId)
+				}
+				got := events[0]
+				if got.EntryId != want.EntryId || got.Entity != want.Entity || got.Action != models.AuditPurge || got.Actor != "trash purge" {
+					t.Errorf("got event %+v, want a purge of %s %d", got, want.Entity, want.EntryId)
+				}
+			}
+
+			if _, err := store.GetEntry(keptEntries[1]); err != nil {
+				t.Errorf("entry outside the trash was purged: %v", err)
+			}
+		})
+	}
+}
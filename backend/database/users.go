@@ -0,0 +1,95 @@
+package database
+
+import (
+	"backend/models"
+	"time"
+)
+
+// CreateUser creates a new user in the database
+// Returns the ID of the new user on success, ErrDuplicate if the username is taken, or an error on failure
+func (s *SQLStore) CreateUser(u *models.User) (int, error) {
+	var id int
+	err := s.db.QueryRow(`
+		INSERT INTO users (username, password_hash, created_at)
+		VALUES ($1, $2, $3) RETURNING id
+	`, u.Username, u.PasswordHash, s.dialect.storeTime(u.CreatedAt.UTC())).Scan(&id)
+	if err != nil && s.dialect.uniqueViolation(err) {
+		return 0, ErrDuplicate
+	}
+	return id, err
+}
+
+// GetUser returns a user from the database by ID
+// Returns the user on success or an error on failure
+func (s *SQLStore) GetUser(id int) (*models.User, error) {
+	u := &models.User{}
+	err := scanUser(u, s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// FindUserByUsername returns the user with the given username
+// Returns the user on success or sql.ErrNoRows if there is none
+func (s *SQLStore) FindUserByUsername(username string) (*models.User, error) {
+	u := &models.User{}
+	err := scanUser(u, s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = $1`, username))
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// CountUsers returns the number of registered users
+func (s *SQLStore) CountUsers() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)
+	return count, err
+}
+
+// CreateSession stores a session, identified by the hash of its token
+// Returns an error on failure
+func (s *SQLStore) CreateSession(session *models.Session) error {
+	_, err := s.db.Exec(`
+		INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, session.TokenHash, session.UserId, s.dialect.storeTime(session.CreatedAt.UTC()),
+		s.dialect.storeTime(session.ExpiresAt.UTC()))
+	return err
+}
+
+// GetSession returns the session with the given token hash, whether it expired or not
+// Returns the session on success or sql.ErrNoRows if there is none
+func (s *SQLStore) GetSession(tokenHash string) (*models.Session, error) {
+	session := &models.Session{}
+	err := s.db.QueryRow(`
+		SELECT token_hash, user_id, created_at, expires_at
+		FROM sessions WHERE token_hash = $1
+	`, tokenHash).Scan(&session.TokenHash, &session.UserId, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// DeleteSession deletes the session with the given token hash, if it exists
+// Returns an error on failure
+func (s *SQLStore) DeleteSession(tokenHash string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE token_hash = $1`, tokenHash)
+	return err
+}
+
+// DeleteExpiredSessions deletes all sessions that expired before now
+// Returns an error on failure
+func (s *SQLStore) DeleteExpiredSessions(now time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at <= $1`, s.dialect.storeTime(now.UTC()))
+	return err
+}
+
+// userColumns are the columns scanUser reads, in order
+const userColumns = "id, username, password_hash, created_at"
+
+func scanUser(u *models.User, row rowScanner) error {
+	return row.Scan(&u.Id, &u.Username, &u.PasswordHash, &u.CreatedAt)
+}
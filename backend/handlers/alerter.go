@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/notify"
+	"backend/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// alertDateFormat is how dates are written in alert messages
+const alertDateFormat = "2006-01-02"
+
+// Alerter evaluates the alert rules of datasets and delivers their alerts.
+// A rule raises one alert when its condition starts to hold and raises the next one only after it stopped holding.
+type Alerter struct {
+	store     database.Store
+	notifiers map[string]notify.Notifier
+	// mu serializes evaluating rules, so a condition starting to hold raises only one alert.
+	// It is not held while alerts are delivered.
+	mu sync.Mutex
+}
+
+// NewAlerter returns an alerter delivering alerts through the given notifiers
+func NewAlerter(store database.Store, notifiers ...notify.Notifier) *Alerter {
+	a := &Alerter{store: store, notifiers: map[string]notify.Notifier{}}
+	for _, n := range notifiers {
+		a.notifiers[n.Name()] = n
+	}
+	return a
+}
+
+// Notifiers returns the names of the notifiers alert rules can use, sorted
+func (a *Alerter) Notifiers() []string {
+	names := make([]string, 0, len(a.notifiers))
+	for name := range a.notifiers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Evaluate evaluates the rules of a dataset, or of all datasets not in the trash if datasetID is 0.
+// A dataset failing to evaluate does not keep the rules of the others from being evaluated.
+// Alerts are delivered after the evaluation, so a slow notifier does not hold up other evaluations.
+func (a *Alerter) Evaluate(datasetID int) error {
+	pending, err := a.raiseAlerts(datasetID)
+	for _, alert := range pending {
+		if deliverErr := a.deliver(alert); deliverErr != nil {
+			err = errors.Join(err, fmt.Errorf("alert rule %d: %w", alert.RuleId, deliverErr))
+		}
+	}
+	return err
+}
+
+// pendingAlert is an alert raised by a rule that is yet to be delivered to the rule's target
+type pendingAlert struct {
+	models.Alert
+	target string
+}
+
+// raiseAlerts evaluates the rules and marks those whose condition started to hold as firing.
+// Returns the alerts these rules raised, which are delivered and recorded by the caller.
+func (a *Alerter) raiseAlerts(datasetID int) ([]pendingAlert, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rules, err := a.store.ListAlertRules(datasetID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	var pending []pendingAlert
+	var errs []error
+	var input *alertInput
+	var loadErr error
+	for _, rule := range rules {
+		// Rules are ordered by dataset, so each dataset is loaded once
+		if input == nil || input.dataset.Id != rule.DatasetId {
+			if input, loadErr = a.loadAlertInput(rule.DatasetId); loadErr != nil {
+				errs = append(errs, fmt.Errorf("dataset %d: %w", rule.DatasetId, loadErr))
+			}
+		}
+		if loadErr != nil {
+			continue
+		}
+		alert, err := a.evaluateRule(rule, input, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alert rule %d: %w", rule.Id, err))
+		}
+		if alert != nil {
+			pending = append(pending, *alert)
+		}
+	}
+	return pending, errors.Join(errs...)
+}
+
+// evaluateRule marks a rule as firing and returns its alert if the condition starts to hold,
+// or rearms the rule once it stopped holding
+func (a *Alerter) evaluateRule(rule models.AlertRule, input *alertInput, now time.Time) (*pendingAlert, error) {
+	holds, value, message, err := input.check(rule, now)
+	if err != nil {
+		return nil, err
+	}
+	if !holds {
+		if rule.Firing {
+			return nil, a.store.SetAlertRuleFiring(rule.Id, false, nil)
+		}
+		return nil, nil
+	}
+	if rule.Firing {
+		return nil, nil
+	}
+
+	if err := a.store.SetAlertRuleFiring(rule.Id, true, &now); err != nil {
+		return nil, err
+	}
+	return &pendingAlert{
+		Alert: models.Alert{
+			RuleId:    rule.Id,
+			DatasetId: rule.DatasetId,
+			RuleName:  rule.Name,
+			Condition: rule.Condition,
+			Message:   message,
+			Value:     value,
+			Notifier:  rule.Notifier,
+			CreatedAt: now,
+		},
+		target: rule.Target,
+	}, nil
+}
+
+// deliver sends an alert through the notifier of its rule and records it with the outcome
+func (a *Alerter) deliver(pending pendingAlert) error {
+	alert := pending.Alert
+	if n, ok := a.notifiers[alert.Notifier]; !ok {
+		alert.Error = "notifier " + alert.Notifier + " is not configured"
+	} else if err := n.Notify(pending.target, alert); err != nil {
+		alert.Error = err.Error()
+	} else {
+		alert.Delivered = true
+	}
+	if !alert.Delivered {
+		utils.Error(fmt.Sprintf("Failed to deliver alert of rule %d: %s", alert.RuleId, alert.Error))
+	}
+	_, err := a.store.CreateAlert(&alert)
+	return err
+}
+
+// alertInput is the dataset the rules are evaluated against, with its entries and forecasts
+type alertInput struct {
+	dataset   models.Dataset
+	entries   []models.Entry
+	summaries map[string]models.ForecastSummary
+}
+
+// loadAlertInput loads a dataset and its entries. The input is returned even on failure, to recognize the dataset.
+func (a *Alerter) loadAlertInput(datasetID int) (*alertInput, error) {
+	input := &alertInput{dataset: models.Dataset{Id: datasetID}}
+	d, err := a.store.GetDataset(datasetID)
+	if err != nil {
+		return input, err
+	}
+	entries, err := a.store.ListEntriesByDataset(datasetID)
+	if err != nil {
+		return input, err
+	}
+	return &alertInput{dataset: *d, entries: entries, summaries: map[string]models.ForecastSummary{}}, nil
+}
+
+// summary returns the forecast summary of the dataset with the given projection method
+func (in *alertInput) summary(method string) (models.ForecastSummary, error) {
+	if method == "" {
+		method = methodAverage
+	}
+	if s, ok := in.summaries[method]; ok {
+		return s, nil
+	}
+	projector, ok := GetProjector(method)
+	if !ok {
+		return models.ForecastSummary{}, errors.New(invalidMethod + " " + method)
+	}
+	s, err := Summarize(in.dataset, in.entries, projector, url.Values{})
+	if err != nil {
+		return models.ForecastSummary{}, err
+	}
+	in.summaries[method] = s
+	return s, nil
+}
+
+// check reports whether the condition of a rule holds, with the value it is about and a message describing it
+func (in *alertInput) check(rule models.AlertRule, now time.Time) (bool, *float64, string, error) {
+	name := in.dataset.Name
+	if rule.Condition == models.AlertNoEntries {
+		// Without entries, the period starts when the rule was created
+		last := rule.CreatedAt
+		for i, e := range in.entries {
+			if i == 0 || e.Date.After(last) {
+				last = e.Date
+			}
+		}
+		holds := now.Sub(last) >= time.Duration(rule.Days)*24*time.Hour
+		return holds, nil, fmt.Sprintf("%s has had no new entry for %d days", name, rule.Days), nil
+	}
+
+	s, err := in.summary(rule.Method)
+	if err != nil {
+		return false, nil, "", err
+	}
+	switch rule.Condition {
+	case models.AlertThreshold:
+		if s.LastValue == nil || rule.Threshold == nil {
+			return false, nil, "", nil
+		}
+		if rule.Direction == models.DirectionBelow {
+			return *s.LastValue <= *rule.Threshold, s.LastValue,
+				fmt.Sprintf("%s is at %s, at or below %s", name, formatFloat(*s.LastValue), formatFloat(*rule.Threshold)), nil
+		}
+		return *s.LastValue >= *rule.Threshold, s.LastValue,
+			fmt.Sprintf("%s is at %s, at or above %s", name, formatFloat(*s.LastValue), formatFloat(*rule.Threshold)), nil
+	case models.AlertTargetReached:
+		if s.TargetStatus != targetStatusReached {
+			return false, nil, "", nil
+		}
+		return true, s.LastValue, fmt.Sprintf("%s reached its target of %s on %s", name, formatFloat(*s.TargetValue),
+			s.TargetDate.Format(alertDateFormat)), nil
+	case models.AlertDeadlineMissed:
+		if s.TargetValue == nil || s.EndDate == nil || s.TargetStatus == targetStatusReached || len(in.entries) < 2 {
+			return false, nil, "", nil
+		}
+		target, endDate := formatFloat(*s.TargetValue), s.EndDate.Format(alertDateFormat)
+		if s.TargetStatus == targetStatusProjected {
+			return s.TargetDate.After(*s.EndDate), s.ValueAtEndDate,
+				fmt.Sprintf("%s is projected to reach its target of %s on %s, after its end date %s", name, target,
+					s.TargetDate.Format(alertDateFormat), endDate), nil
+		}
+		return true, s.ValueAtEndDate,
+			fmt.Sprintf("%s is not projected to reach its target of %s by its end date %s: %s", name, target, endDate, s.Reason), nil
+	}
+	return false, nil, "", fmt.Errorf("unknown condition %q", rule.Condition)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/notify"
+	"testing"
+	"time"
+)
+
+// blockingNotifier delivers alerts only once release is closed
+type blockingNotifier struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (*blockingNotifier) Name() string { return "blocking" }
+
+func (n *blockingNotifier) Notify(string, models.Alert) error {
+	close(n.started)
+	<-n.release
+	return nil
+}
+
+func TestAlerterEvaluatesWhileDeliveryHangs(t *testing.T) {
+	h, u, hangingID := newTestHandler(t)
+	otherID, err := h.Store.CreateDataset(&models.Dataset{Name: "other", Kind: models.KindCumulative, OwnerId: u.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	threshold := 1.0
+	for datasetID, notifier := range map[int]string{hangingID: "blocking", otherID: models.NotifierLog} {
+		if _, err := h.Store.CreateEntry(&models.Entry{DatasetId: datasetID, Value: 2, Date: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+		rule := models.AlertRule{DatasetId: datasetID, Name: "above one", Condition: models.AlertThreshold,
+			Threshold: &threshold, Direction: models.DirectionAbove, Notifier: notifier, CreatedAt: time.Now()}
+		if _, err := h.Store.CreateAlertRule(&rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocking := &blockingNotifier{started: make(chan struct{}), release: make(chan struct{})}
+	alerter := NewAlerter(h.Store, blocking, notify.Log{})
+
+	hanging := make(chan error)
+	go func() { hanging <- alerter.Evaluate(hangingID) }()
+	<-blocking.started
+
+	done := make(chan error)
+	go func() { done <- alerter.Evaluate(otherID) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("evaluation waited for the hanging delivery")
+	}
+
+	close(blocking.release)
+	if err := <-hanging; err != nil {
+		t.Fatal(err)
+	}
+	alerts, err := h.Store.ListAlerts(models.AlertFilter{DatasetId: hangingID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || !alerts[0].Delivered {
+		t.Errorf("got %+v, want one delivered alert", alerts)
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/utils"
+	"backend/validation"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+const (
+	ruleId            = "ruleId"
+	invalidRuleId     = "invalid alert rule id"
+	alertRuleNotFound = "alert rule not found"
+)
+
+// ListAlertRulesHandler lists the alert rules of a dataset with whether their condition currently holds
+func (h *Handler) ListAlertRulesHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if _, err := h.authorizedDataset(r, datasetId, models.RoleOwner); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	rules, err := h.Store.ListAlertRules(datasetId)
+	handleError(w, err, "")
+	if err == nil {
+		writeJSON(w, rules)
+	}
+}
+
+// CreateAlertRuleHandler adds an alert rule to a dataset and evaluates it right away
+func (h *Handler) CreateAlertRuleHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	var req models.AlertRuleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if _, err := h.authorizedDataset(r, datasetId, models.RoleOwner); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	if req.Condition == models.AlertDeadlineMissed && req.Method == "" {
+		req.Method = methodAverage
+	}
+	var notifiers []string
+	if h.Alerter != nil {
+		notifiers = h.Alerter.Notifiers()
+	}
+	methods := make([]string, 0, len(projectors))
+	for _, info := range ListProjectors() {
+		methods = append(methods, info.Name)
+	}
+	if err := validation.AlertRule(&req, notifiers, methods); err != nil {
+		handleError(w, err, "")
+		return
+	}
+
+	rule := models.AlertRule{
+		DatasetId: datasetId,
+		Name:      req.Name,
+		Condition: req.Condition,
+		Threshold: req.Threshold,
+		Direction: req.Direction,
+		Days:      req.Days,
+		Method:    req.Method,
+		Notifier:  req.Notifier,
+		Target:    req.Target,
+		CreatedBy: currentUser(r).Id,
+		CreatedAt: time.Now().UTC(),
+	}
+	if rule.Id, err = h.Store.CreateAlertRule(&rule); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	writeJSONStatus(w, http.StatusCreated, rule)
+	h.evaluateAlerts(datasetId)
+}
+
+// DeleteAlertRuleHandler deletes an alert rule of a dataset, the alerts it raised stay in the history
+func (h *Handler) DeleteAlertRuleHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	alertRuleId, err := parseID(r, ruleId, invalidRuleId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if _, err := h.authorizedDataset(r, datasetId, models.RoleOwner); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	if err := h.Store.DeleteAlertRule(datasetId, alertRuleId); err != nil {
+		handleError(w, err, alertRuleNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAlertsHandler lists the alerts raised for a dataset, newest first, optionally only those of one rule
+func (h *Handler) ListAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	query := r.URL.Query()
+	filter := models.AlertFilter{DatasetId: datasetId, Limit: defaultHistoryLimit}
+	limit, err := parseOptionalInt(query, "limit", 1, maxPageSize)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if limit > 0 {
+		filter.Limit = limit
+	}
+	if filter.Offset, err = parseOptionalInt(query, "offset", 0, math.MaxInt32); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if filter.RuleId, err = parseOptionalInt(query, ruleId, 1, math.MaxInt32); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if _, err := h.authorizedDataset(r, datasetId, models.RoleViewer); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	alerts, err := h.Store.ListAlerts(filter)
+	handleError(w, err, "")
+	if err == nil {
+		writeJSON(w, alerts)
+	}
+}
+
+// evaluateAlerts evaluates the alert rules of a dataset after it or its entries changed.
+// It runs in the background, so slow notifiers do not delay the response.
+func (h *Handler) evaluateAlerts(datasetID int) {
+	if h.Alerter == nil {
+		return
+	}
+	go func() {
+		if err := h.Alerter.Evaluate(datasetID); err != nil {
+			utils.Error(fmt.Sprintf("Failed to evaluate alert rules of dataset %d: %s", datasetID, err))
+		}
+	}()
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/validation"
+	"net/http"
+	"time"
+)
+
+const (
+	// apiKeyPrefix starts every API key, telling them apart from session tokens
+	apiKeyPrefix = "dtk_"
+	// apiKeyDisplayLength is the length of the start of a key kept readable to recognize it
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+
+	keyId          = "keyId"
+	invalidKeyId   = "invalid API key id"
+	apiKeyNotFound = "API key not found"
+)
+
+// ListAPIKeysHandler lists the API keys of a dataset, including revoked ones. The keys themselves are not stored.
+func (h *Handler) ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if _, err := h.authorizedDataset(r, datasetId, models.RoleOwner); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	keys, err := h.Store.ListAPIKeys(datasetId)
+	handleError(w, err, "")
+	if err == nil {
+		writeJSON(w, keys)
+	}
+}
+
+// CreateAPIKeyHandler creates an API key reading or writing the entries of a dataset.
+// The key is only part of this response, afterwards only its hash is known.
+func (h *Handler) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	var req models.APIKeyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if _, err := h.authorizedDataset(r, datasetId, models.RoleOwner); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	if err := validation.APIKey(&req); err != nil {
+		handleError(w, err, "")
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	key := apiKeyPrefix + token
+	created := models.CreatedAPIKey{
+		APIKey: models.APIKey{
+			DatasetId: datasetId,
+			Name:      req.Name,
+			Scope:     req.Scope,
+			Prefix:    key[:apiKeyDisplayLength],
+			KeyHash:   hashToken(key),
+			CreatedBy: currentUser(r).Id,
+			CreatedAt: time.Now().UTC(),
+		},
+		Key: key,
+	}
+	if created.Id, err = h.Store.CreateAPIKey(&created.APIKey); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	writeJSONStatus(w, http.StatusCreated, created)
+}
+
+// RevokeAPIKeyHandler revokes an API key of a dataset, it is rejected from then on
+func (h *Handler) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	apiKeyId, err := parseID(r, keyId, invalidKeyId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if _, err := h.authorizedDataset(r, datasetId, models.RoleOwner); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	if err := h.Store.RevokeAPIKey(datasetId, apiKeyId, time.Now()); err != nil {
+		handleError(w, err, apiKeyNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
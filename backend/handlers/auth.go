@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+	"backend/validation"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+
+	// sessionLifetime is how long a token issued by LoginHandler is accepted
+	sessionLifetime = 30 * 24 * time.Hour
+	tokenBytes      = 32
+)
+
+type contextKey int
+
+// Request context keys of the user or API key a request is authenticated with
+const (
+	userKey contextKey = iota
+	apiKeyKey
+)
+
+var (
+	errUnauthorized       = &httpError{http.StatusUnauthorized, "authentication required"}
+	errInvalidToken       = &httpError{http.StatusUnauthorized, "invalid or expired token"}
+	errInvalidCredentials = &httpError{http.StatusUnauthorized, "invalid username or password"}
+	errUsernameTaken      = &httpError{http.StatusConflict, "username is already taken"}
+)
+
+// dummyHash is compared against if a username does not exist,
+// so failed logins take equally long and do not reveal which usernames exist
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// RegisterHandler creates a user account. The first account registered becomes the owner of
+// all datasets created before accounts existed.
+func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var c models.Credentials
+	if err := decodeJSON(r, &c); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if err := validation.Credentials(&c); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(c.Password), bcrypt.DefaultCost)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+
+	u := models.User{Username: c.Username, PasswordHash: string(hash), CreatedAt: time.Now()}
+	err = h.Store.InTransaction(func(tx database.Store) error {
+		existing, err := tx.CountUsers()
+		if err != nil {
+			return err
+		}
+		if u.Id, err = tx.CreateUser(&u); err != nil {
+			return err
+		}
+		if existing == 0 {
+			return tx.ClaimUnownedDatasets(u.Id)
+		}
+		return nil
+	})
+	if errors.Is(err, database.ErrDuplicate) {
+		err = errUsernameTaken
+	}
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+
+	created, err := h.Store.GetUser(u.Id)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	writeJSONStatus(w, http.StatusCreated, created)
+}
+
+// LoginHandler checks a username and password and returns a bearer token for the Authorization header
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var c models.Credentials
+	if err := decodeJSON(r, &c); err != nil {
+		handleError(w, err, "")
+		return
+	}
+
+	u, err := h.Store.FindUserByUsername(strings.ToLower(strings.TrimSpace(c.Username)))
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(c.Password))
+		handleError(w, errInvalidCredentials, "")
+		return
+	case err != nil:
+		handleError(w, err, "")
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(c.Password)); err != nil {
+		handleError(w, errInvalidCredentials, "")
+		return
+	}
+
+	now := time.Now()
+	if err := h.Store.DeleteExpiredSessions(now); err != nil {
+		utils.Error("failed to delete expired sessions: " + err.Error())
+	}
+	token, err := newToken()
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	session := models.Session{TokenHash: hashToken(token), UserId: u.Id, CreatedAt: now, ExpiresAt: now.Add(sessionLifetime)}
+	if err := h.Store.CreateSession(&session); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	writeJSON(w, models.LoginResponse{Token: token, ExpiresAt: session.ExpiresAt.UTC(), User: *u})
+}
+
+// LogoutHandler ends the session of the token the request is authenticated with
+func (h *Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	token, _ := bearerToken(r)
+	if err := h.Store.DeleteSession(hashToken(token)); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MeHandler returns the authenticated user
+func (h *Handler) MeHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, currentUser(r))
+}
+
+// Authenticate is a middleware rejecting requests without a valid bearer token with 401 Unauthorized.
+// The authenticated user is available to the handlers through currentUser.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return h.authenticateRequests(next, false)
+}
+
+// AuthenticateWithAPIKey is Authenticate also accepting API keys, for the routes scripts and devices may use.
+// The API key of a request is available to the handlers through currentAPIKey.
+func (h *Handler) AuthenticateWithAPIKey(next http.Handler) http.Handler {
+	return h.authenticateRequests(next, true)
+}
+
+func (h *Handler) authenticateRequests(next http.Handler, acceptAPIKeys bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := h.authenticate(r, acceptAPIKeys)
+		if err != nil {
+			var httpErr *httpError
+			if errors.As(err, &httpErr) && httpErr.code == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="dataTracker"`)
+			}
+			handleError(w, err, "")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate returns the request context with the user of the session or the API key
+// the bearer token of the request belongs to
+func (h *Handler) authenticate(r *http.Request, acceptAPIKeys bool) (context.Context, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	if strings.HasPrefix(token, apiKeyPrefix) {
+		key, err := h.Store.GetAPIKeyByHash(hashToken(token))
+		switch {
+		case err == nil:
+			if err := h.checkAPIKey(key, acceptAPIKeys); err != nil {
+				return nil, err
+			}
+			return context.WithValue(r.Context(), apiKeyKey, key), nil
+		case !errors.Is(err, database.ErrNotFound):
+			return nil, err
+		}
+	}
+
+	session, err := h.Store.GetSession(hashToken(token))
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !session.ExpiresAt.After(time.Now())) {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	u, err := h.Store.GetUser(session.UserId)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(r.Context(), userKey, u), nil
+}
+
+// checkAPIKey checks that an API key is not revoked and accepted by the route, and records its use
+func (h *Handler) checkAPIKey(key *models.APIKey, acceptAPIKeys bool) error {
+	if key.RevokedAt != nil {
+		return errInvalidToken
+	}
+	if !acceptAPIKeys {
+		return &httpError{http.StatusForbidden, "API keys are not accepted for this route"}
+	}
+	if err := h.Store.TouchAPIKey(key.Id, time.Now()); err != nil {
+		utils.Error("failed to record API key use: " + err.Error())
+	}
+	return nil
+}
+
+// currentUser returns the user authenticated by Authenticate, nil if the request uses an API key
+func currentUser(r *http.Request) *models.User {
+	u, _ := r.Context().Value(userKey).(*models.User)
+	return u
+}
+
+// currentAPIKey returns the API key accepted by AuthenticateWithAPIKey, nil if the request is made by a user
+func currentAPIKey(r *http.Request) *models.APIKey {
+	k, _ := r.Context().Value(apiKeyKey).(*models.APIKey)
+	return k
+}
+
+// roleRanks orders the roles of dataset members, each role may do everything the lower ranked roles may
+var roleRanks = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+// apiKeyRoles maps the scopes of API keys to the role they grant in their dataset
+var apiKeyRoles = map[string]string{
+	models.ScopeRead:  models.RoleViewer,
+	models.ScopeWrite: models.RoleEditor,
+}
+
+// authorizedDataset loads a dataset the authenticated user has at least the given role in.
+// Datasets the user has no role in are reported as not found, so their IDs are not disclosed.
+// API keys act as viewer of their dataset, or as editor with the write scope.
+func (h *Handler) authorizedDataset(r *http.Request, id int, role string) (*models.Dataset, error) {
+	key := currentAPIKey(r)
+	if key != nil && key.DatasetId != id {
+		return nil, database.ErrNotFound
+	}
+	d, err := h.Store.GetDataset(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.authorize(r, d, role); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// authorize checks that the authenticated user or API key has at least the given role in a dataset,
+// returning ErrNotFound if the user has no role in it
+func (h *Handler) authorize(r *http.Request, d *models.Dataset, role string) error {
+	if key := currentAPIKey(r); key != nil {
+		if key.DatasetId != d.Id {
+			return database.ErrNotFound
+		}
+		if roleRanks[role] > roleRanks[apiKeyRoles[key.Scope]] {
+			return &httpError{http.StatusForbidden, "this requires an API key with write scope"}
+		}
+		return nil
+	}
+	userRole, err := h.datasetRole(d, currentUser(r).Id)
+	if err != nil {
+		return err
+	}
+	if roleRanks[userRole] < roleRanks[role] {
+		return &httpError{http.StatusForbidden, "this requires the " + role + " role for the dataset"}
+	}
+	return nil
+}
+
+// authorizedEntry loads an entry of a dataset the authenticated user has at least the given role in
+func (h *Handler) authorizedEntry(r *http.Request, id int, role string) (*models.Entry, error) {
+	e, err := h.Store.GetEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.authorizedDataset(r, e.DatasetId, role); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// datasetRole returns the role of a user in a dataset, ErrNotFound if the user has none
+func (h *Handler) datasetRole(d *models.Dataset, userID int) (string, error) {
+	if d.OwnerId == userID {
+		return models.RoleOwner, nil
+	}
+	m, err := h.Store.GetDatasetMember(d.Id, userID)
+	if err != nil {
+		return "", err
+	}
+	return m.Role, nil
+}
+
+// bearerToken returns the token of the Authorization header of a request
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get(authorizationHeader)
+	if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(bearerPrefix):]), true
+}
+
+// newToken returns a random session token
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hash a session token is stored as, so a leaked database does not contain usable tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"backend/models"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+const (
+	cadenceDaily     = "daily"
+	cadenceWeekly    = "weekly"
+	cadenceMonthly   = "monthly"
+	cadenceQuarterly = "quarterly"
+	cadenceYearly    = "yearly"
+	cadenceIrregular = "irregular"
+)
+
+// cadence is the interval between consecutive entries of a dataset.
+// Calendar cadences step in months or days, so projections stay on month boundaries and keep
+// their wall-clock time across DST changes. Irregular cadences fall back to a fixed duration.
+type cadence struct {
+	name   string
+	months int
+	days   int
+	step   time.Duration
+	// anchorDay is the day of the month calendar month steps land on, clamped to the end of shorter months
+	anchorDay int
+}
+
+// cadenceRange maps a range of typical gaps between entries in days to a calendar cadence
+type cadenceRange struct {
+	minDays, maxDays float64
+	cadence          cadence
+}
+
+var cadenceRanges = []cadenceRange{
+	{0.9, 1.1, cadence{name: cadenceDaily, days: 1}},
+	{6.5, 7.5, cadence{name: cadenceWeekly, days: 7}},
+	{27, 32, cadence{name: cadenceMonthly, months: 1}},
+	{88, 93, cadence{name: cadenceQuarterly, months: 3}},
+	{360, 370, cadence{name: cadenceYearly, months: 12}},
+}
+
+// resolveCadence returns the cadence requested in the query or detects it from the sorted entries
+func resolveCadence(entries []models.Entry, query url.Values) (cadence, error) {
+	c, err := parseCadence(query)
+	if err != nil || c.valid() {
+		return c, err
+	}
+	return detectCadence(entries), nil
+}
+
+// parseCadence returns the cadence requested in the query, or the zero cadence if none is requested
+func parseCadence(query url.Values) (cadence, error) {
+	name := query.Get("cadence")
+	if name == "" {
+		return cadence{}, nil
+	}
+	for _, r := range cadenceRanges {
+		if r.cadence.name == name {
+			return r.cadence, nil
+		}
+	}
+	return cadence{}, &httpError{http.StatusBadRequest, "invalid cadence, expected daily, weekly, monthly, quarterly or yearly"}
+}
+
+// detectCadence classifies the median gap between the sorted entries.
+// The median ignores the occasional missing or doubled entry that would skew an average.
+func detectCadence(entries []models.Entry) cadence {
+	gaps := make([]float64, 0, len(entries)-1)
+	for i := 1; i < len(entries); i++ {
+		gaps = append(gaps, daysBetween(entries[i-1].Date, entries[i].Date))
+	}
+	sort.Float64s(gaps)
+
+	median := gaps[len(gaps)/2]
+	if len(gaps)%2 == 0 {
+		median = (gaps[len(gaps)/2-1] + gaps[len(gaps)/2]) / 2
+	}
+
+	for _, r := range cadenceRanges {
+		if median >= r.minDays && median <= r.maxDays {
+			return r.cadence
+		}
+	}
+
+	_, avgStep := calcAverageChange(entries)
+	return cadence{name: cadenceIrregular, step: avgStep}
+}
+
+// parseTimeZone loads the time zone used for calendar arithmetic, defaulting to UTC
+func parseTimeZone(query url.Values) (*time.Location, error) {
+	name := query.Get("timeZone")
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, &httpError{http.StatusBadRequest, "invalid timeZone: " + name}
+	}
+	return loc, nil
+}
+
+// anchoredTo returns the cadence with its anchor day taken from the sorted entries in loc.
+// The newest entry that cannot have been clamped to the end of a short month gives the day,
+// so a series on the 30th stays on the 30th after an entry on February 28.
+// Series only ever on the last day of short months are taken to be on the last day of every month.
+func (c cadence) anchoredTo(entries []models.Entry, loc *time.Location) cadence {
+	c.anchorDay = 31
+	for i := len(entries) - 1; i >= 0; i-- {
+		date := entries[i].Date.In(loc)
+		if day := date.Day(); day == 31 || day < daysInMonth(date) {
+			c.anchorDay = day
+			break
+		}
+	}
+	return c
+}
+
+// valid reports whether stepping with the cadence moves forward in time
+func (c cadence) valid() bool {
+	return c.months > 0 || c.days > 0 || c.step > 0
+}
+
+// dateAt returns the date step intervals after origin.
+// Each date is computed from origin rather than from the previous date, and month steps land on the
+// anchor day rather than the day of origin, so clamped month ends do not drift.
+func (c cadence) dateAt(origin time.Time, step int) time.Time {
+	switch {
+	case c.months > 0:
+		return addMonths(origin, c.months*step, c.anchorDay)
+	case c.days > 0:
+		return origin.AddDate(0, 0, c.days*step)
+	default:
+		return origin.Add(time.Duration(step) * c.step)
+	}
+}
+
+// addMonths adds months to date and moves it to anchorDay, clamping the day to the end of shorter months.
+// An anchorDay of 0 keeps the day of date.
+func addMonths(date time.Time, months, anchorDay int) time.Time {
+	year, month, day := date.Date()
+	hour, minute, sec := date.Clock()
+	if anchorDay > 0 {
+		day = anchorDay
+	}
+
+	firstOfTarget := time.Date(year, month+time.Month(months), 1, hour, minute, sec, date.Nanosecond(), date.Location())
+	if lastDay := daysInMonth(firstOfTarget); day > lastDay {
+		day = lastDay
+	}
+	return firstOfTarget.AddDate(0, 0, day-1)
+}
+
+// daysInMonth returns the number of days in the month of date
+func daysInMonth(date time.Time) int {
+	return time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+)
+
+func TestMonthlyCadenceKeepsAnchorDay(t *testing.T) {
+	monthly := cadence{name: cadenceMonthly, months: 1}
+	tests := []struct {
+		name    string
+		history []string
+		want    []string
+	}{
+		{"28th", []string{"2024-12-28", "2025-01-28", "2025-02-28"},
+			[]string{"2025-03-28", "2025-04-28", "2025-05-28"}},
+		{"29th", []string{"2024-12-29", "2025-01-29", "2025-02-28"},
+			[]string{"2025-03-29", "2025-04-29", "2025-05-29"}},
+		{"29th in a leap year", []string{"2023-12-29", "2024-01-29", "2024-02-29"},
+			[]string{"2024-03-29", "2024-04-29", "2024-05-29"}},
+		{"30th", []string{"2024-12-30", "2025-01-30", "2025-02-28"},
+			[]string{"2025-03-30", "2025-04-30", "2025-05-30"}},
+		{"30th from before February", []string{"2024-11-30", "2024-12-30", "2025-01-30"},
+			[]string{"2025-02-28", "2025-03-30", "2025-04-30"}},
+		{"31st", []string{"2024-12-31", "2025-01-31", "2025-02-28"},
+			[]string{"2025-03-31", "2025-04-30", "2025-05-31"}},
+		{"last day of short months only", []string{"2025-02-28", "2025-04-30"},
+			[]string{"2025-05-31", "2025-06-30", "2025-07-31"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := make([]models.Entry, len(tt.history))
+			for i, raw := range tt.history {
+				entries[i] = models.Entry{Date: mustDate(t, raw)}
+			}
+			c := monthly.anchoredTo(entries, time.UTC)
+			origin := entries[len(entries)-1].Date
+			for i, raw := range tt.want {
+				if got := c.dateAt(origin, i+1); !got.Equal(mustDate(t, raw)) {
+					t.Errorf("step %d: got %s, want %s", i+1, got.Format(time.DateOnly), raw)
+				}
+			}
+		})
+	}
+}
+
+func mustDate(t *testing.T, raw string) time.Time {
+	t.Helper()
+	date, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return date
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxImportSize limits the size of an imported CSV file in bytes
+	maxImportSize = 10 << 20
+
+	uploadFormField = "file"
+)
+
+// dateFormatTokens translates spreadsheet style date formats such as DD.MM.YYYY into Go layouts.
+// Longer tokens come first, so YYYY is not read as two YY.
+var dateFormatTokens = strings.NewReplacer(
+	"YYYY", "2006",
+	"YY", "06",
+	"MM", "01",
+	"DD", "02",
+	"HH", "15",
+	"mm", "04",
+	"ss", "05",
+)
+
+// csvImportOptions configures how the rows of an imported CSV file are mapped to entries
+type csvImportOptions struct {
+	header      bool
+	delimiter   rune
+	dateColumn  string
+	valueColumn string
+	labelColumn string
+	dateLayout  string
+	decimal     string
+	thousands   string
+	loc         *time.Location
+}
+
+// csvColumns holds the indices of the mapped columns, label is -1 if the file has no label column
+type csvColumns struct {
+	date  int
+	value int
+	label int
+}
+
+// parseCSVImportOptions reads the column mapping and number and date formats from the query
+func parseCSVImportOptions(query url.Values) (csvImportOptions, error) {
+	opts := csvImportOptions{
+		header:      query.Get("header") != "false",
+		delimiter:   ',',
+		dateColumn:  query.Get("dateColumn"),
+		valueColumn: query.Get("valueColumn"),
+		labelColumn: query.Get("labelColumn"),
+		decimal:     ".",
+		thousands:   ",",
+	}
+
+	switch delimiter := query.Get("delimiter"); delimiter {
+	case "":
+	case "tab", `\t`:
+		opts.delimiter = '\t'
+	case "semicolon":
+		// A literal ; has to be escaped as %3B in a query string, so it also has a name
+		opts.delimiter = ';'
+	default:
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) || r == '"' || r == '\r' || r == '\n' {
+			return opts, &httpError{http.StatusBadRequest, "invalid delimiter, expected a single character"}
+		}
+		opts.delimiter = r
+	}
+
+	switch query.Get("decimal") {
+	case "", ".":
+	case ",":
+		opts.decimal, opts.thousands = ",", "."
+	default:
+		return opts, &httpError{http.StatusBadRequest, "invalid decimal separator, expected . or ,"}
+	}
+	if opts.delimiter == ',' && opts.decimal == "," {
+		return opts, &httpError{http.StatusBadRequest, "decimal separator , requires a different delimiter, such as ;"}
+	}
+
+	if format := query.Get("dateFormat"); format != "" {
+		opts.dateLayout = dateFormatTokens.Replace(format)
+	}
+
+	loc, err := parseTimeZone(query)
+	if err != nil {
+		return opts, err
+	}
+	opts.loc = loc
+	return opts, nil
+}
+
+// openUpload returns the file of a multipart upload or the raw request body, limited to maxSize bytes
+func openUpload(w http.ResponseWriter, r *http.Request, maxSize int64) (io.ReadCloser, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(contentTypeString))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	// Parsing the form reads the whole body, so an upload over maxSize fails here rather than when reading the file
+	err := r.ParseMultipartForm(maxSize)
+	var file io.ReadCloser
+	if err == nil {
+		file, _, err = r.FormFile(uploadFormField)
+	}
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return nil, &httpError{http.StatusRequestEntityTooLarge, fmt.Sprintf("upload exceeds %d bytes", maxSize)}
+	case err != nil:
+		return nil, &httpError{http.StatusBadRequest, "missing file in form field " + uploadFormField}
+	}
+	return file, nil
+}
+
+// readCSVEntries parses every record of the CSV file into an entry of the dataset.
+// Records that cannot be parsed are reported with their line number instead of failing the whole file.
+// Returns an error only if the file itself is malformed or lacks a mapped column.
+func readCSVEntries(body io.Reader, datasetID int, opts csvImportOptions) ([]models.ImportRowResult, error) {
+	reader := csv.NewReader(body)
+	reader.Comma = opts.delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var header []string
+	if opts.header {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, &httpError{http.StatusBadRequest, "CSV file is empty"}
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		// Spreadsheet applications prefix UTF-8 exports with a byte order mark
+		record[0] = strings.TrimPrefix(record[0], "\ufeff")
+		header = record
+	}
+
+	columns, err := resolveColumns(header, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []models.ImportRowResult{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		result := models.ImportRowResult{Row: line}
+		entry, err := parseCSVRecord(record, columns, opts)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			entry.DatasetId = datasetID
+			result.Entry = &entry
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// resolveColumns finds the mapped columns by header name or zero-based index.
+// Without a mapping, the columns named date, value and label are used, or the first three columns if there is no header.
+func resolveColumns(header []string, opts csvImportOptions) (csvColumns, error) {
+	defaults := [3]string{"date", "value", "label"}
+	if header == nil {
+		defaults = [3]string{"0", "1", "2"}
+	}
+
+	resolve := func(mapping, fallback string, required bool) (int, error) {
+		name := mapping
+		if name == "" {
+			name = fallback
+		}
+		if index, err := strconv.Atoi(name); err == nil && index >= 0 && (header == nil || index < len(header)) {
+			return index, nil
+		}
+		for i, column := range header {
+			if strings.EqualFold(strings.TrimSpace(column), name) {
+				return i, nil
+			}
+		}
+		if !required && mapping == "" {
+			return -1, nil
+		}
+		return 0, &httpError{http.StatusBadRequest, fmt.Sprintf("column %q not found in CSV file", name)}
+	}
+
+	var columns csvColumns
+	var err error
+	if columns.date, err = resolve(opts.dateColumn, defaults[0], true); err != nil {
+		return columns, err
+	}
+	if columns.value, err = resolve(opts.valueColumn, defaults[1], true); err != nil {
+		return columns, err
+	}
+	if columns.label, err = resolve(opts.labelColumn, defaults[2], false); err != nil {
+		return columns, err
+	}
+	return columns, nil
+}
+
+// parseCSVRecord converts a single CSV record into an entry
+func parseCSVRecord(record []string, columns csvColumns, opts csvImportOptions) (models.Entry, error) {
+	var e models.Entry
+	field := func(index int) string {
+		if index < 0 || index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	rawDate := field(columns.date)
+	if rawDate == "" {
+		return e, errors.New("missing date")
+	}
+	date, err := parseImportDate(rawDate, opts)
+	if err != nil {
+		return e, err
+	}
+
+	rawValue := field(columns.value)
+	if rawValue == "" {
+		return e, errors.New("missing value")
+	}
+	value, err := parseDecimal(rawValue, opts)
+	if err != nil {
+		return e, err
+	}
+
+	e.Date = date
+	e.Value = value
+	e.Label = field(columns.label)
+	return e, nil
+}
+
+// parseImportDate parses a date with the configured layout, or as RFC 3339 or YYYY-MM-DD if none is configured
+func parseImportDate(raw string, opts csvImportOptions) (time.Time, error) {
+	if opts.dateLayout != "" {
+		date, err := time.ParseInLocation(opts.dateLayout, raw, opts.loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", raw)
+		}
+		return date, nil
+	}
+	if date, err := time.Parse(time.RFC3339, raw); err == nil {
+		return date, nil
+	}
+	date, err := time.ParseInLocation(time.DateOnly, raw, opts.loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected RFC 3339 or YYYY-MM-DD", raw)
+	}
+	return date, nil
+}
+
+// groupedNumbers match numbers with thousands separators by decimal separator. Separators are only
+// accepted between groups of three digits, so a number written with the other decimal separator,
+// such as 1,5 while . is expected, is rejected rather than read as 15.
+var groupedNumbers = map[string]*regexp.Regexp{
+	".": regexp.MustCompile(`^[+-]?\d{1,3}([, \x{a0}]\d{3})+(\.\d+)?$`),
+	",": regexp.MustCompile(`^[+-]?\d{1,3}([. \x{a0}]\d{3})+(,\d+)?$`),
+}
+
+// parseDecimal parses a number with the configured decimal and thousands separators, e.g. 1.234,56.
+// Spaces may separate thousands as well.
+func parseDecimal(raw string, opts csvImportOptions) (float64, error) {
+	normalized := strings.TrimSpace(raw)
+	if strings.ContainsAny(normalized, opts.thousands+" \u00a0") {
+		if !groupedNumbers[opts.decimal].MatchString(normalized) {
+			return 0, fmt.Errorf("invalid value %q", raw)
+		}
+		normalized = strings.NewReplacer(" ", "", "\u00a0", "", opts.thousands, "").Replace(normalized)
+	}
+	normalized = strings.Replace(normalized, opts.decimal, ".", 1)
+	value, err := strconv.ParseFloat(normalized, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid value %q", raw)
+	}
+	return value, nil
+}
+
+// csvError turns a CSV or body size error into a bad request
+func csvError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &httpError{http.StatusRequestEntityTooLarge, fmt.Sprintf("CSV file exceeds %d bytes", maxImportSize)}
+	}
+	return &httpError{http.StatusBadRequest, "invalid CSV: " + err.Error()}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/models"
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// newTestHandler returns a handler on an empty memory store with a user owning one dataset
+func newTestHandler(t *testing.T) (*Handler, *models.User, int) {
+	t.Helper()
+	store := database.NewMemoryStore()
+	u := &models.User{Username: "owner"}
+	userID, err := store.CreateUser(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Id = userID
+	datasetID, err := store.CreateDataset(&models.Dataset{Name: "meter", Kind: models.KindCumulative, OwnerId: userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Handler{Store: store}, u, datasetID
+}
+
+// requestAs returns a request authenticated as u with the given route variables
+func requestAs(r *http.Request, u *models.User, vars map[string]string) *http.Request {
+	r = r.WithContext(context.WithValue(r.Context(), userKey, u))
+	return mux.SetURLVars(r, vars)
+}
+
+func oversizeCSV() []byte {
+	rows := bytes.Repeat([]byte("2025-01-01,1\n"), maxImportSize/13+1)
+	return append([]byte("date,value\n"), rows...)
+}
+
+func TestImportEntriesRejectsOversizeRawBody(t *testing.T) {
+	h, u, datasetID := newTestHandler(t)
+	r := httptest.NewRequest(http.MethodPost, "/datasets/1/import", bytes.NewReader(oversizeCSV()))
+	r.Header.Set(contentTypeString, "text/csv")
+	w := httptest.NewRecorder()
+
+	h.ImportEntriesHandler(w, requestAs(r, u, map[string]string{datasetId: strconv.Itoa(datasetID)}))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %d, want %d: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body)
+	}
+}
+
+func TestImportEntriesRejectsOversizeMultipartUpload(t *testing.T) {
+	h, u, datasetID := newTestHandler(t)
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(uploadFormField, "entries.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(oversizeCSV()); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/datasets/1/import", &body)
+	r.Header.Set(contentTypeString, form.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	h.ImportEntriesHandler(w, requestAs(r, u, map[string]string{datasetId: strconv.Itoa(datasetID)}))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %d, want %d: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "exceeds") {
+		t.Errorf("got %s, want a size error", w.Body)
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		raw     string
+		decimal string
+		want    float64
+		wantErr bool
+	}{
+		{raw: "1.5", decimal: ".", want: 1.5},
+		{raw: "1,234.56", decimal: ".", want: 1234.56},
+		{raw: "-1,234,567", decimal: ".", want: -1234567},
+		{raw: "1 234.5", decimal: ".", want: 1234.5},
+		{raw: "1,5", decimal: ".", wantErr: true},
+		{raw: "1,23,4", decimal: ".", wantErr: true},
+		{raw: "12,34", decimal: ".", wantErr: true},
+		{raw: "1,5", decimal: ",", want: 1.5},
+		{raw: "1.234,56", decimal: ",", want: 1234.56},
+		{raw: "1.5", decimal: ",", wantErr: true},
+		{raw: "1.23.4", decimal: ",", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw+" with decimal "+tt.decimal, func(t *testing.T) {
+			opts, err := parseCSVImportOptions(url.Values{"decimal": {tt.decimal}, "delimiter": {"semicolon"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseDecimal(tt.raw, opts)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Errorf("got %g, want an error", got)
+			case !tt.wantErr && err != nil:
+				t.Errorf("got error %v, want %g", err, tt.want)
+			case !tt.wantErr && got != tt.want:
+				t.Errorf("got %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
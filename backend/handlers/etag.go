@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	etagHeader        = "ETag"
+	ifMatchHeader     = "If-Match"
+	ifNoneMatchHeader = "If-None-Match"
+)
+
+// errModified is returned if a dataset or entry changed since the client read it
+var errModified = &httpError{http.StatusPreconditionFailed, "the resource was modified in the meantime, reload it and try again"}
+
+// etag returns the entity tag of a dataset or entry with the given version
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// checkIfMatch compares the If-Match header of a request against the current version of a dataset or entry.
+// Returns the version to update or delete, or 0 if the request has no If-Match header or If-Match is *.
+func checkIfMatch(r *http.Request, current int) (int, error) {
+	header := strings.TrimSpace(r.Header.Get(ifMatchHeader))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if !matchesETag(header, current) {
+		return 0, errModified
+	}
+	return current, nil
+}
+
+// matchesETag reports whether a comma separated list of entity tags contains the tag of version.
+// Weak tags never match, as If-Match requires a strong comparison.
+func matchesETag(header string, version int) bool {
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimSpace(tag) == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeVersioned writes v with the ETag of its version, or only 304 Not Modified if the client has it already
+func writeVersioned(w http.ResponseWriter, r *http.Request, version int, v any) {
+	w.Header().Set(etagHeader, etag(version))
+	if header := r.Header.Get(ifNoneMatchHeader); header != "" && matchesETag(strings.ReplaceAll(header, "W/", ""), version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, v)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportWriter streams datasets with their entries into an export file
+type exportWriter interface {
+	WriteDataset(d models.Dataset, entries []models.Entry) error
+	// Close finishes the export file, it does not close the underlying writer
+	Close() error
+}
+
+// exportFormat describes a supported export file format
+type exportFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(w io.Writer) (exportWriter, error)
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv": {
+		contentType: "text/csv; charset=utf-8",
+		extension:   "csv",
+		newWriter:   newCSVExportWriter,
+	},
+	"json": {
+		contentType: contentType,
+		extension:   "json",
+		newWriter:   newJSONExportWriter,
+	},
+	"xlsx": {
+		contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		extension:   "xlsx",
+		newWriter:   newXLSXExportWriter,
+	},
+}
+
+// csvExportColumns are the columns of a CSV export.
+// The date, value and label columns match the defaults of the CSV import.
+var csvExportColumns = []string{
+	"dataset_id", "dataset_name", "symbol", "kind", "date", "value", "label", "projected", "lower", "upper",
+}
+
+// csvExportWriter writes one row per entry, repeating the dataset metadata in every row.
+// Text cells that a spreadsheet would run as a formula are prefixed with ', see escapeFormula.
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer) (exportWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvExportColumns); err != nil {
+		return nil, err
+	}
+	return &csvExportWriter{w: cw}, nil
+}
+
+func (c *csvExportWriter) WriteDataset(d models.Dataset, entries []models.Entry) error {
+	for _, e := range entries {
+		record := []string{
+			strconv.Itoa(d.Id),
+			escapeFormula(d.Name),
+			escapeFormula(d.Symbol),
+			d.Kind,
+			e.Date.Format(time.RFC3339),
+			formatFloat(e.Value),
+			escapeFormula(e.Label),
+			strconv.FormatBool(e.Projected),
+			formatOptionalFloat(e.Lower),
+			formatOptionalFloat(e.Upper),
+		}
+		if err := c.w.Write(record); err != nil {
+			return err
+		}
+	}
+	// Flush per dataset, so large exports reach the client while the next dataset is loaded
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvExportWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonExportWriter writes a single JSON document, encoding each dataset as soon as it is written
+type jsonExportWriter struct {
+	w     io.Writer
+	enc   *json.Encoder
+	count int
+}
+
+func newJSONExportWriter(w io.Writer) (exportWriter, error) {
+	exportedAt, err := json.Marshal(time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(w, `{"exportedAt":%s,"datasets":[`, exportedAt); err != nil {
+		return nil, err
+	}
+	return &jsonExportWriter{w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (j *jsonExportWriter) WriteDataset(d models.Dataset, entries []models.Entry) error {
+	if j.count > 0 {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+	j.count++
+	if entries == nil {
+		entries = []models.Entry{}
+	}
+	return j.enc.Encode(models.DatasetExport{Dataset: d, Entries: entries})
+}
+
+func (j *jsonExportWriter) Close() error {
+	_, err := io.WriteString(j.w, "]}\n")
+	return err
+}
+
+// formulaPrefixes are the characters a spreadsheet treats as the start of a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula prefixes text starting like a formula with ', so spreadsheets show it as text instead of running it.
+// Numeric cells are not escaped, their leading - is a sign.
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune(formulaPrefixes, rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// formatFloat formats a value with the minimal number of digits needed to read it back exactly
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatOptionalFloat formats a value or returns an empty string if it is nil
+func formatOptionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return formatFloat(*v)
+}
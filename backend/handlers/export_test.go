@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/csv"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExportRejectsInvalidProjectionBeforeStarting(t *testing.T) {
+	h, u, datasetID := newTestHandler(t)
+	d, err := h.Store.GetDataset(datasetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+	d.EndDate = &end
+	if err := h.Store.UpdateDataset(d); err != nil {
+		t.Fatal(err)
+	}
+	for i, value := range []float64{1, 2, 3} {
+		date := time.Date(2025, time.Month(i+1), 1, 0, 0, 0, 0, time.UTC)
+		if _, err := h.Store.CreateEntry(&models.Entry{DatasetId: datasetID, Value: value, Date: date}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, query := range []string{"cadence=hourly", "timeZone=Nowhere/City"} {
+		t.Run(query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/datasets/1/export?projected=true&"+query, nil)
+			w := httptest.NewRecorder()
+
+			h.ExportDatasetHandler(w, requestAs(r, u, map[string]string{id: strconv.Itoa(datasetID)}))
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+			}
+			if disposition := w.Header().Get("Content-Disposition"); disposition != "" {
+				t.Errorf("got Content-Disposition %q, want none", disposition)
+			}
+		})
+	}
+}
+
+func TestExportCSVEscapesFormulas(t *testing.T) {
+	h, u, datasetID := newTestHandler(t)
+	d, err := h.Store.GetDataset(datasetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Name, d.Symbol = "=HYPERLINK(\"http://example.com\")", "@"
+	if err := h.Store.UpdateDataset(d); err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := h.Store.CreateEntry(&models.Entry{DatasetId: datasetID, Value: -5, Label: "+1+1", Date: date}); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/datasets/1/export?format=csv", nil)
+	w := httptest.NewRecorder()
+
+	h.ExportDatasetHandler(w, requestAs(r, u, map[string]string{id: strconv.Itoa(datasetID)}))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := records[1]
+	want := map[int]string{1: `'=HYPERLINK("http://example.com")`, 2: "'@", 5: "-5", 6: "'+1+1"}
+	for column, value := range want {
+		if row[column] != value {
+			t.Errorf("%s = %q, want %q", csvExportColumns[column], row[column], value)
+		}
+	}
+}
+
+func TestExportProjectedIncrementalDatasetKeepsIncrements(t *testing.T) {
+	h, u, datasetID := newTestHandler(t)
+	d, err := h.Store.GetDataset(datasetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	d.Kind, d.EndDate = models.KindIncremental, &end
+	if err := h.Store.UpdateDataset(d); err != nil {
+		t.Fatal(err)
+	}
+	for day := 1; day <= 3; day++ {
+		date := time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC)
+		if _, err := h.Store.CreateEntry(&models.Entry{DatasetId: datasetID, Value: 2, Date: date}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(http.MethodGet, "/datasets/1/export?format=csv&projected=true&method=linear", nil)
+	w := httptest.NewRecorder()
+
+	h.ExportDatasetHandler(w, requestAs(r, u, map[string]string{id: strconv.Itoa(datasetID)}))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 7 {
+		t.Fatalf("got %d rows, want a header, 3 stored and 3 projected entries", len(records))
+	}
+	for _, row := range records[1:] {
+		value, err := strconv.ParseFloat(row[5], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(value-2) > 1e-9 {
+			t.Errorf("%s (projected %s): value = %g, want the increment 2", row[4], row[7], value)
+		}
+	}
+}
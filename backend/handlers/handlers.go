@@ -1,14 +1,21 @@
 package handlers
 
 import (
+	"backend/backup"
 	"backend/database"
 	"backend/models"
 	"backend/utils"
-	"database/sql"
+	"backend/validation"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -22,10 +29,41 @@ const (
 	invalidDatasetId = "invalid dataset id"
 	invalidEntryId   = "invalid entry id"
 	datasetNotFound  = "dataset not found"
+	entryNotFound    = "entry not found"
+
+	method        = "method"
+	methodAverage = "average"
+	until         = "until"
+	invalidMethod = "invalid projection method"
+
+	validationFailed = "validation_failed"
+
+	viewRaw        = "raw"
+	viewCumulative = "cumulative"
+
+	totalCountHeader = "X-Total-Count"
+	maxPageSize      = 1000
+
+	defaultExportFormat = "csv"
+
+	batchCreate        = "create"
+	batchUpdate        = "update"
+	batchDelete        = "delete"
+	maxBatchOperations = 1000
+
+	// maxRestoreSize limits the size of a restored archive in bytes, and of the JSON inside a ZIP archive
+	maxRestoreSize = 200 << 20
+
+	defaultBucket      = "month"
+	defaultAggregateFn = "sum"
 )
 
 type Handler struct {
-	DB *sql.DB
+	Store database.Store
+	// TrashRetention is how long deleted datasets and entries are kept in the trash before they are purged
+	TrashRetention time.Duration
+	// Alerter evaluates the alert rules of datasets whose entries change, nil disables alerts
+	Alerter *Alerter
 }
 
 func (h *Handler) CreateDatasetHandler(w http.ResponseWriter, r *http.Request) {
@@ -34,12 +72,19 @@ func (h *Handler) CreateDatasetHandler(w http.ResponseWriter, r *http.Request) {
 		handleError(w, err, "")
 		return
 	}
-	id, err := database.CreateDataset(h.DB, &d)
+	if err := validation.Dataset(&d); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	d.OwnerId = currentUser(r).Id
+	id, err := h.auditedStore(r).CreateDataset(&d)
 	if err != nil {
 		handleError(w, err, "")
 		return
 	}
-	d.Id = id
+	// Every store starts new datasets at version 1
+	d.Id, d.Version = id, 1
+	w.Header().Set(etagHeader, etag(d.Version))
 	writeJSON(w, d)
 }
 
@@ -49,21 +94,23 @@ func (h *Handler) GetDatasetHandler(w http.ResponseWriter, r *http.Request) {
 		handleError(w, err, "")
 		return
 	}
-	d, err := database.GetDataset(h.DB, id)
-	handleError(w, err, datasetNotFound)
-	if err == nil {
-		writeJSON(w, d)
+	d, err := h.authorizedDataset(r, id, models.RoleViewer)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
 	}
+	writeVersioned(w, r, d.Version, d)
 }
 
-func (h *Handler) ListDatasetsHandler(w http.ResponseWriter, _ *http.Request) {
-	datasets, err := database.ListDatasets(h.DB)
+func (h *Handler) ListDatasetsHandler(w http.ResponseWriter, r *http.Request) {
+	datasets, err := h.Store.ListDatasets(currentUser(r).Id)
 	handleError(w, err, "")
 	if err == nil {
 		writeJSON(w, datasets)
 	}
 }
 
+// UpdateDatasetHandler replaces all fields of a dataset and returns the updated dataset
 func (h *Handler) UpdateDatasetHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := parseID(r, id, invalidDatasetId)
 	if err != nil {
@@ -75,12 +122,66 @@ func (h *Handler) UpdateDatasetHandler(w http.ResponseWriter, r *http.Request) {
 		handleError(w, err, "")
 		return
 	}
-	d.Id = id
-	if err := database.UpdateDataset(h.DB, &d); err != nil {
+	existing, err := h.authorizedDataset(r, id, models.RoleEditor)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	if d.Version, err = checkIfMatch(r, existing.Version); err != nil {
 		handleError(w, err, "")
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	h.saveDataset(w, r, id, d)
+}
+
+// PatchDatasetHandler updates the fields of a dataset present in a JSON Merge Patch and returns the updated dataset
+func (h *Handler) PatchDatasetHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	var patch json.RawMessage
+	if err := decodeJSON(r, &patch); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	existing, err := h.authorizedDataset(r, id, models.RoleEditor)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	d := *existing
+	if err := applyMergePatch(&d, patch); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if d.Version, err = checkIfMatch(r, existing.Version); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	h.saveDataset(w, r, id, d)
+}
+
+// saveDataset validates and stores d as the dataset with the given ID, responding with the stored dataset
+func (h *Handler) saveDataset(w http.ResponseWriter, r *http.Request, id int, d models.Dataset) {
+	if err := validation.Dataset(&d); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	d.Id = id
+	if err := h.auditedStore(r).UpdateDataset(&d); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	updated, err := h.Store.GetDataset(id)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	w.Header().Set(etagHeader, etag(updated.Version))
+	writeJSON(w, updated)
+	h.evaluateAlerts(id)
 }
 
 func (h *Handler) DeleteDatasetHandler(w http.ResponseWriter, r *http.Request) {
@@ -89,10 +190,20 @@ func (h *Handler) DeleteDatasetHandler(w http.ResponseWriter, r *http.Request) {
 		handleError(w, err, "")
 		return
 	}
-	if err := database.DeleteDataset(h.DB, id); err != nil {
+	existing, err := h.authorizedDataset(r, id, models.RoleOwner)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	version, err := checkIfMatch(r, existing.Version)
+	if err != nil {
 		handleError(w, err, "")
 		return
 	}
+	if err := h.auditedStore(r).DeleteDataset(id, version); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -107,29 +218,192 @@ func (h *Handler) CreateEntryHandler(w http.ResponseWriter, r *http.Request) {
 		handleError(w, err, "")
 		return
 	}
+	dataset, err := h.authorizedDataset(r, datasetId, models.RoleEditor)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	if err := validation.Entry(&e, dataset); err != nil {
+		handleError(w, err, "")
+		return
+	}
 	e.DatasetId = datasetId
-	id, err := database.CreateEntry(h.DB, &e)
+	id, err := h.auditedStore(r).CreateEntry(&e)
 	if err != nil {
 		handleError(w, err, "")
 		return
 	}
-	e.Id = id
+	e.Id, e.Version = id, 1
+	w.Header().Set(etagHeader, etag(e.Version))
 	writeJSON(w, e)
+	h.evaluateAlerts(datasetId)
 }
 
+// ListEntriesHandler lists the entries of a dataset, optionally filtered, sorted and paginated.
+// The number of entries matching the filter is returned in the X-Total-Count header.
 func (h *Handler) ListEntriesHandler(w http.ResponseWriter, r *http.Request) {
 	datasetId, err := parseID(r, datasetId, invalidDatasetId)
 	if err != nil {
 		handleError(w, err, "")
 		return
 	}
-	entries, err := database.ListEntriesByDataset(h.DB, datasetId)
+	filter, err := parseEntryFilter(r.URL.Query())
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+
+	dataset, err := h.authorizedDataset(r, datasetId, models.RoleViewer)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	switch r.URL.Query().Get("view") {
+	case "", viewRaw:
+	case viewCumulative:
+		filter.Cumulative = dataset.Kind == models.KindIncremental
+	default:
+		handleError(w, &httpError{http.StatusBadRequest, "invalid view, expected raw or cumulative"}, "")
+		return
+	}
+
+	entries, total, err := h.Store.ListEntries(datasetId, filter)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	w.Header().Set(totalCountHeader, strconv.Itoa(total))
+	writeJSON(w, entries)
+}
+
+// ImportEntriesHandler imports entries from a CSV file in a single transaction.
+// If any row is invalid, nothing is imported and the report is returned with 422 Unprocessable Entity.
+// With dryRun=true the file is only validated.
+func (h *Handler) ImportEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, datasetId, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	opts, err := parseCSVImportOptions(r.URL.Query())
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	dataset, err := h.authorizedDataset(r, datasetId, models.RoleEditor)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+
+	body, err := openUpload(w, r, maxImportSize)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	defer func(body io.ReadCloser) {
+		if err := body.Close(); err != nil {
+			utils.Error(err.Error())
+		}
+	}(body)
+
+	rows, err := readCSVEntries(body, datasetId, opts)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+
+	report := models.ImportReport{DryRun: r.URL.Query().Get("dryRun") == "true", Total: len(rows), Rows: rows}
+	for i, row := range rows {
+		if row.Error == "" {
+			if err := validation.Entry(row.Entry, dataset); err != nil {
+				rows[i].Entry, rows[i].Error = nil, err.Error()
+				row = rows[i]
+			}
+		}
+		if row.Error == "" {
+			report.Valid++
+		} else {
+			report.Invalid++
+		}
+	}
+	if report.Invalid > 0 {
+		writeJSONStatus(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+	if report.DryRun {
+		writeJSON(w, report)
+		return
+	}
+
+	err = h.auditedStore(r).InTransaction(func(tx database.Store) error {
+		for _, row := range rows {
+			id, err := tx.CreateEntry(row.Entry)
+			if err != nil {
+				return err
+			}
+			row.Entry.Id = id
+		}
+		return nil
+	})
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	report.Imported = len(rows)
+	writeJSON(w, report)
+	h.evaluateAlerts(datasetId)
+}
+
+// AggregateEntriesHandler resamples the entries of a dataset into day, week, month, quarter or year buckets
+func (h *Handler) AggregateEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, datasetId, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	query := r.URL.Query()
+	series := models.Series{DatasetId: datasetId, Bucket: query.Get("bucket"), Fn: query.Get("fn")}
+	if series.Bucket == "" {
+		series.Bucket = defaultBucket
+	}
+	if series.Fn == "" {
+		series.Fn = defaultAggregateFn
+	}
+	if !database.ValidBucket(series.Bucket) {
+		handleError(w, &httpError{http.StatusBadRequest, "invalid bucket, expected day, week, month, quarter or year"}, "")
+		return
+	}
+	if !database.ValidAggregateFunction(series.Fn) {
+		handleError(w, &httpError{http.StatusBadRequest, "invalid fn, expected sum, avg, min, max, last or count"}, "")
+		return
+	}
+	if _, err := h.authorizedDataset(r, datasetId, models.RoleViewer); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	series.Points, err = h.Store.AggregateEntries(datasetId, series.Bucket, series.Fn)
 	handleError(w, err, "")
 	if err == nil {
-		writeJSON(w, entries)
+		writeJSON(w, series)
 	}
 }
 
+func (h *Handler) GetEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, id, invalidEntryId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	e, err := h.authorizedEntry(r, id, models.RoleViewer)
+	if err != nil {
+		handleError(w, err, entryNotFound)
+		return
+	}
+	writeVersioned(w, r, e.Version, e)
+}
+
+// UpdateEntryHandler replaces the value, label and date of an entry and returns the updated entry
 func (h *Handler) UpdateEntryHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := parseID(r, id, invalidEntryId)
 	if err != nil {
@@ -141,12 +415,72 @@ func (h *Handler) UpdateEntryHandler(w http.ResponseWriter, r *http.Request) {
 		handleError(w, err, "")
 		return
 	}
-	e.Id = id
-	if err := database.UpdateEntry(h.DB, &e); err != nil {
+	existing, err := h.authorizedEntry(r, id, models.RoleEditor)
+	if err != nil {
+		handleError(w, err, entryNotFound)
+		return
+	}
+	if e.Version, err = checkIfMatch(r, existing.Version); err != nil {
 		handleError(w, err, "")
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	h.saveEntry(w, r, existing, e)
+}
+
+// PatchEntryHandler updates the fields of an entry present in a JSON Merge Patch and returns the updated entry
+func (h *Handler) PatchEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, id, invalidEntryId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	var patch json.RawMessage
+	if err := decodeJSON(r, &patch); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	existing, err := h.authorizedEntry(r, id, models.RoleEditor)
+	if err != nil {
+		handleError(w, err, entryNotFound)
+		return
+	}
+	e := *existing
+	if err := applyMergePatch(&e, patch); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if e.Version, err = checkIfMatch(r, existing.Version); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	h.saveEntry(w, r, existing, e)
+}
+
+// saveEntry validates and stores e in place of the existing entry, responding with the stored entry.
+// An entry cannot be moved to another dataset.
+func (h *Handler) saveEntry(w http.ResponseWriter, r *http.Request, existing *models.Entry, e models.Entry) {
+	dataset, err := h.Store.GetDataset(existing.DatasetId)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	if err := validation.Entry(&e, dataset); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	e.Id, e.DatasetId = existing.Id, existing.DatasetId
+	if err := h.auditedStore(r).UpdateEntry(&e); err != nil {
+		handleError(w, err, entryNotFound)
+		return
+	}
+	updated, err := h.Store.GetEntry(e.Id)
+	if err != nil {
+		handleError(w, err, entryNotFound)
+		return
+	}
+	w.Header().Set(etagHeader, etag(updated.Version))
+	writeJSON(w, updated)
+	h.evaluateAlerts(updated.DatasetId)
 }
 
 func (h *Handler) DeleteEntryHandler(w http.ResponseWriter, r *http.Request) {
@@ -155,45 +489,556 @@ func (h *Handler) DeleteEntryHandler(w http.ResponseWriter, r *http.Request) {
 		handleError(w, err, "")
 		return
 	}
-	if err := database.DeleteEntry(h.DB, id); err != nil {
+	existing, err := h.authorizedEntry(r, id, models.RoleEditor)
+	if err != nil {
+		handleError(w, err, entryNotFound)
+		return
+	}
+	version, err := checkIfMatch(r, existing.Version)
+	if err != nil {
 		handleError(w, err, "")
 		return
 	}
+	if err := h.auditedStore(r).DeleteEntry(id, version); err != nil {
+		handleError(w, err, entryNotFound)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// BatchEntriesHandler applies a list of create, update and delete operations to the entries of a dataset
+// in a single transaction. Either all operations are applied or, if any of them fails, none is and the
+// report is returned with 422 Unprocessable Entity, marking the failed operations.
+func (h *Handler) BatchEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, datasetId, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	var req models.BatchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if len(req.Operations) == 0 {
+		handleError(w, &httpError{http.StatusBadRequest, "operations must not be empty"}, "")
+		return
+	}
+	if len(req.Operations) > maxBatchOperations {
+		handleError(w, &httpError{http.StatusBadRequest,
+			fmt.Sprintf("too many operations, at most %d are allowed", maxBatchOperations)}, "")
+		return
+	}
+	dataset, err := h.authorizedDataset(r, datasetId, models.RoleEditor)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+
+	report := models.BatchReport{Results: make([]models.BatchOperationResult, len(req.Operations))}
+	invalid := false
+	for i, op := range req.Operations {
+		report.Results[i] = models.BatchOperationResult{Index: i, Op: op.Op, Id: op.Id}
+		if msg := validateBatchOperation(op, dataset); msg != "" {
+			report.Results[i].Error = msg
+			invalid = true
+		}
+	}
+	if invalid {
+		writeJSONStatus(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	failed := false
+	err = h.auditedStore(r).InTransaction(func(tx database.Store) error {
+		for i, op := range req.Operations {
+			result := &report.Results[i]
+			err := applyBatchOperation(tx, datasetId, op, result)
+			switch {
+			case errors.Is(err, database.ErrNotFound):
+				result.Error = "entry not found in dataset"
+			case errors.Is(err, database.ErrConflict):
+				result.Error = fmt.Sprintf("entry no longer has version %d", op.Version)
+			}
+			if result.Error != "" {
+				failed = true
+				return err
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if failed {
+		writeJSONStatus(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	report.Applied = true
+	writeJSON(w, report)
+	h.evaluateAlerts(datasetId)
+}
+
+// validateBatchOperation checks an operation before anything is written, returning why it is invalid
+func validateBatchOperation(op models.BatchOperation, dataset *models.Dataset) string {
+	switch op.Op {
+	case batchCreate:
+		if op.Entry == nil {
+			return "create requires an entry"
+		}
+	case batchUpdate:
+		if op.Id <= 0 {
+			return "update requires an id"
+		}
+		if op.Entry == nil {
+			return "update requires an entry"
+		}
+	case batchDelete:
+		if op.Id <= 0 {
+			return "delete requires an id"
+		}
+	default:
+		return "invalid op, expected create, update or delete"
+	}
+	if op.Entry != nil {
+		if err := validation.Entry(op.Entry, dataset); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// applyBatchOperation runs a single validated operation, recording the written entry in result
+func applyBatchOperation(tx database.Store, datasetID int, op models.BatchOperation, result *models.BatchOperationResult) error {
+	switch op.Op {
+	case batchCreate:
+		e := *op.Entry
+		e.DatasetId = datasetID
+		id, err := tx.CreateEntry(&e)
+		if err != nil {
+			return err
+		}
+		e.Id, e.Version = id, 1
+		result.Id, result.Entry = id, &e
+	case batchUpdate:
+		e := *op.Entry
+		e.Id, e.DatasetId, e.Version = op.Id, datasetID, op.Version
+		if err := tx.UpdateDatasetEntry(&e); err != nil {
+			return err
+		}
+		updated, err := tx.GetEntry(e.Id)
+		if err != nil {
+			return err
+		}
+		result.Entry = updated
+	case batchDelete:
+		return tx.DeleteDatasetEntry(datasetID, op.Id, op.Version)
+	}
+	return nil
+}
+
+func (h *Handler) ListProjectorsHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, ListProjectors())
+}
+
+// ProjectedHandler projects entries with the projector selected by the method query parameter
+// until the target, the end date or an explicit date selected by the until query parameter
+func (h *Handler) ProjectedHandler(w http.ResponseWriter, r *http.Request) {
+	projection, err := h.projectEntries(r, r.URL.Query().Get(until))
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	writeJSON(w, projection)
+}
+
 func (h *Handler) ProjectedUntilTargetHandler(w http.ResponseWriter, r *http.Request) {
-	h.projectEntries(w, r, ProjectUntilTarget)
+	h.writeLegacyProjection(w, r, untilTarget)
 }
 
 func (h *Handler) ProjectedUntilEndDateHandler(w http.ResponseWriter, r *http.Request) {
-	h.projectEntries(w, r, ProjectUntilEndDate)
+	h.writeLegacyProjection(w, r, untilEndDate)
+}
+
+// writeLegacyProjection serves the fixed /projected/target and /projected/endDate routes.
+// Without a method, only the entries are written to keep the original response shape.
+func (h *Handler) writeLegacyProjection(w http.ResponseWriter, r *http.Request, until string) {
+	projection, err := h.projectEntries(r, until)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	if r.URL.Query().Get(method) == "" {
+		writeJSON(w, projection.Entries)
+		return
+	}
+	writeJSON(w, projection)
+}
+
+// ForecastSummaryHandler summarizes when the dataset will reach its target and where it will be at its end date
+func (h *Handler) ForecastSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	dataset, entries, projector, err := h.loadProjectionInput(r, id)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	summary, err := Summarize(dataset, entries, projector, r.URL.Query())
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	writeJSON(w, summary)
 }
 
 // projectEntries is a generic helper for projections
-func (h *Handler) projectEntries(w http.ResponseWriter, r *http.Request, projector func(models.Dataset, []models.Entry) []models.Entry,
-) {
-	datasetId, err := parseID(r, datasetId, invalidDatasetId)
+func (h *Handler) projectEntries(r *http.Request, until string) (models.Projection, error) {
+	dataset, entries, projector, err := h.loadProjectionInput(r, datasetId)
+	if err != nil {
+		return models.Projection{}, err
+	}
+	return Project(dataset, entries, projector, until, r.URL.Query())
+}
+
+// loadProjectionInput loads the dataset identified by the idKey path variable, its entries
+// and the projector selected by the method query parameter
+func (h *Handler) loadProjectionInput(r *http.Request, idKey string) (models.Dataset, []models.Entry, Projector, error) {
+	datasetId, err := parseID(r, idKey, invalidDatasetId)
+	if err != nil {
+		return models.Dataset{}, nil, nil, err
+	}
+	name := r.URL.Query().Get(method)
+	if name == "" {
+		name = methodAverage
+	}
+	projector, ok := GetProjector(name)
+	if !ok {
+		return models.Dataset{}, nil, nil, &httpError{http.StatusBadRequest, invalidMethod}
+	}
+	dataset, err := h.authorizedDataset(r, datasetId, models.RoleViewer)
+	if err != nil {
+		return models.Dataset{}, nil, nil, err
+	}
+	entries, err := h.Store.ListEntriesByDataset(datasetId)
+	if err != nil {
+		return models.Dataset{}, nil, nil, err
+	}
+	return *dataset, entries, projector, nil
+}
+
+// parseEntryFilter reads the filter, sorting and pagination of an entry listing from the query.
+// A to date without a time includes the whole day.
+func parseEntryFilter(query url.Values) (models.EntryFilter, error) {
+	filter := models.EntryFilter{Label: query.Get("q"), Sort: query.Get("sort")}
+	if filter.Sort == "" {
+		filter.Sort = "date"
+	}
+	if !database.ValidEntrySort(filter.Sort) {
+		return filter, &httpError{http.StatusBadRequest, "invalid sort, expected date or value"}
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, &httpError{http.StatusBadRequest, "invalid order, expected asc or desc"}
+	}
+
+	var err error
+	if filter.From, err = parseOptionalDate(query, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseOptionalDate(query, "to"); err != nil {
+		return filter, err
+	}
+	if filter.To != nil && len(query.Get("to")) == len(time.DateOnly) {
+		endOfDay := filter.To.AddDate(0, 0, 1).Add(-time.Microsecond)
+		filter.To = &endOfDay
+	}
+	if filter.MinValue, err = parseOptionalFloat(query, "minValue"); err != nil {
+		return filter, err
+	}
+	if filter.MaxValue, err = parseOptionalFloat(query, "maxValue"); err != nil {
+		return filter, err
+	}
+
+	if filter.Limit, err = parseOptionalInt(query, "limit", 1, maxPageSize); err != nil {
+		return filter, err
+	}
+	if filter.Offset, err = parseOptionalInt(query, "offset", 0, math.MaxInt32); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseOptionalDate parses an RFC 3339 or YYYY-MM-DD date from the query, returning nil if it is absent
+func parseOptionalDate(query url.Values, key string) (*time.Time, error) {
+	if query.Get(key) == "" {
+		return nil, nil
+	}
+	date, err := parseDateParam(query, key, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+// parseOptionalFloat parses a finite number from the query, returning nil if it is absent
+func parseOptionalFloat(query url.Values, key string) (*float64, error) {
+	raw := query.Get(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, &httpError{http.StatusBadRequest, "invalid " + key + ", expected a number"}
+	}
+	return &value, nil
+}
+
+// parseOptionalInt parses an integer between minValue and maxValue from the query, returning 0 if it is absent
+func parseOptionalInt(query url.Values, key string, minValue, maxValue int) (int, error) {
+	raw := query.Get(key)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < minValue || value > maxValue {
+		return 0, &httpError{http.StatusBadRequest, fmt.Sprintf("invalid %s, expected an integer between %d and %d", key, minValue, maxValue)}
+	}
+	return value, nil
+}
+
+// ExportDatasetHandler downloads a dataset with its entries as CSV, JSON or XLSX
+func (h *Handler) ExportDatasetHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, id, invalidDatasetId)
 	if err != nil {
 		handleError(w, err, "")
 		return
 	}
-	dataset, err := database.GetDataset(h.DB, datasetId)
+	d, err := h.authorizedDataset(r, id, models.RoleViewer)
 	if err != nil {
 		handleError(w, err, datasetNotFound)
 		return
 	}
-	entries, err := database.ListEntriesByDataset(h.DB, datasetId)
+	h.export(w, r, []models.Dataset{*d}, fmt.Sprintf("dataset-%d", d.Id))
+}
+
+// ExportAllHandler downloads all datasets with their entries as CSV, JSON or XLSX
+func (h *Handler) ExportAllHandler(w http.ResponseWriter, r *http.Request) {
+	datasets, err := h.Store.ListDatasets(currentUser(r).Id)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	h.export(w, r, datasets, "datatracker-export-"+time.Now().Format(time.DateOnly))
+}
+
+// export streams datasets in the format selected by the format query parameter.
+// With projected=true the entries are extended by the projection selected by the method and until
+// query parameters. Incremental datasets are projected as running totals, but exported with their
+// stored values and projected increments, so an export can be imported again without changing its meaning.
+func (h *Handler) export(w http.ResponseWriter, r *http.Request, datasets []models.Dataset, baseName string) {
+	query := r.URL.Query()
+	formatName := query.Get("format")
+	if formatName == "" {
+		formatName = defaultExportFormat
+	}
+	format, ok := exportFormats[formatName]
+	if !ok {
+		handleError(w, &httpError{http.StatusBadRequest, "invalid format, expected csv, json or xlsx"}, "")
+		return
+	}
+
+	var projector Projector
+	projectUntil := query.Get(until)
+	if query.Get("projected") == "true" {
+		if projectUntil == "" {
+			projectUntil = untilEndDate
+		}
+		name := query.Get(method)
+		if name == "" {
+			name = methodAverage
+		}
+		if projector, ok = GetProjector(name); !ok {
+			handleError(w, &httpError{http.StatusBadRequest, invalidMethod}, "")
+			return
+		}
+		// Validate the projection parameters even if there are no datasets to project
+		if _, err := Project(models.Dataset{}, nil, projector, projectUntil, query); err != nil {
+			handleError(w, err, "")
+			return
+		}
+	}
+
+	// Projections are computed before the response is started, so errors with the real data are still reported.
+	// Exports without projections stream each dataset as soon as its entries are loaded.
+	var projected [][]models.Entry
+	if projector != nil {
+		projected = make([][]models.Entry, len(datasets))
+		for i, d := range datasets {
+			entries, err := h.Store.ListEntriesByDataset(d.Id)
+			if err != nil {
+				handleError(w, err, "")
+				return
+			}
+			projection, err := Project(d, entries, projector, projectUntil, query)
+			if err != nil {
+				handleError(w, err, "")
+				return
+			}
+			projected[i] = projection.Entries
+			if d.Kind == models.KindIncremental {
+				projected[i] = storedIncrements(entries, projection.Entries)
+			}
+		}
+	}
+
+	w.Header().Set(contentTypeString, format.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, baseName, format.extension))
+	ew, err := format.newWriter(w)
+	if err != nil {
+		utils.Error("failed to start export: " + err.Error())
+		return
+	}
+
+	for i, d := range datasets {
+		var entries []models.Entry
+		if projected != nil {
+			entries = projected[i]
+		} else if entries, err = h.Store.ListEntriesByDataset(d.Id); err != nil {
+			utils.Error("failed to load entries for export: " + err.Error())
+			return
+		}
+		if err := ew.WriteDataset(d, entries); err != nil {
+			utils.Error("failed to write export: " + err.Error())
+			return
+		}
+	}
+
+	if err := ew.Close(); err != nil {
+		utils.Error("failed to finish export: " + err.Error())
+	}
+}
+
+// storedIncrements turns the running totals projected for an incremental dataset back into increments,
+// so the value column of an export means the same with and without projections.
+// Entries that were projected get the difference to the total before them, their bounds are moved alike.
+func storedIncrements(stored, projected []models.Entry) []models.Entry {
+	values := make(map[int]float64, len(stored))
+	for _, e := range stored {
+		values[e.Id] = e.Value
+	}
+	increments := make([]models.Entry, len(projected))
+	var prevTotal float64
+	for i, e := range projected {
+		total := e.Value
+		if e.Projected {
+			e.Value -= prevTotal
+			e.Lower, e.Upper = shiftBound(e.Lower, -prevTotal), shiftBound(e.Upper, -prevTotal)
+		} else {
+			e.Value = values[e.Id]
+		}
+		increments[i] = e
+		prevTotal = total
+	}
+	return increments
+}
+
+// shiftBound returns a copy of a prediction bound moved by delta, or nil if there is no bound
+func shiftBound(bound *float64, delta float64) *float64 {
+	if bound == nil {
+		return nil
+	}
+	shifted := *bound + delta
+	return &shifted
+}
+
+// BackupHandler downloads the datasets the user owns and their entries as a versioned JSON or ZIP archive
+func (h *Handler) BackupHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = backup.FormatZIP
+	}
+	if format != backup.FormatJSON && format != backup.FormatZIP {
+		handleError(w, &httpError{http.StatusBadRequest, backup.ErrInvalidFormat.Error()}, "")
+		return
+	}
+	b, err := backup.Dump(h.Store, currentUser(r).Id)
 	if err != nil {
 		handleError(w, err, "")
 		return
 	}
-	writeJSON(w, projector(*dataset, entries))
+
+	if format == backup.FormatZIP {
+		w.Header().Set(contentTypeString, "application/zip")
+	} else {
+		w.Header().Set(contentTypeString, contentType)
+	}
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="datatracker-backup-%s.%s"`, b.CreatedAt.Format(time.DateOnly), format))
+	if err := backup.WriteArchive(w, b, format); err != nil {
+		utils.Error("failed to write backup: " + err.Error())
+	}
+}
+
+// RestoreHandler restores an archive written by BackupHandler, resolving datasets whose name
+// already exists with the strategy query parameter (skip, overwrite or duplicate)
+func (h *Handler) RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	strategy := r.URL.Query().Get("strategy")
+	if strategy == "" {
+		strategy = backup.StrategySkip
+	}
+	if !backup.ValidStrategy(strategy) {
+		handleError(w, &httpError{http.StatusBadRequest, backup.ErrInvalidStrategy.Error()}, "")
+		return
+	}
+
+	body, err := openUpload(w, r, maxRestoreSize)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	defer func(body io.ReadCloser) {
+		if err := body.Close(); err != nil {
+			utils.Error(err.Error())
+		}
+	}(body)
+
+	b, err := backup.ReadArchive(body, maxRestoreSize)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			handleError(w, &httpError{http.StatusRequestEntityTooLarge, fmt.Sprintf("archive exceeds %d bytes", maxRestoreSize)}, "")
+		case errors.Is(err, backup.ErrInvalidArchive) || errors.Is(err, backup.ErrUnsupportedVersion):
+			handleError(w, &httpError{http.StatusBadRequest, err.Error()}, "")
+		default:
+			handleError(w, err, "")
+		}
+		return
+	}
+
+	report, err := backup.Restore(h.auditedStore(r), b, strategy, currentUser(r).Id)
+	handleError(w, err, "")
+	if err == nil {
+		writeJSON(w, report)
+	}
 }
 
 // writeJSON writes a JSON response with proper headers
 func writeJSON(w http.ResponseWriter, v interface{}) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+// writeJSONStatus writes a JSON response with proper headers and the given status code
+func writeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set(contentTypeString, contentType)
+	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(v); err != nil {
 		utils.Error("failed to write JSON response: " + err.Error())
 	}
@@ -217,20 +1062,34 @@ func decodeJSON(r *http.Request, v interface{}) error {
 	return nil
 }
 
-// handleError handles errors and writes HTTP responses accordingly
+// handleError handles errors and writes JSON error responses accordingly
 func handleError(w http.ResponseWriter, err error, notFoundMsg string) {
 	if err == nil {
 		return
 	}
 	var httpErr *httpError
+	var validationErr *validation.Error
 	switch {
+	case errors.As(err, &validationErr):
+		writeError(w, http.StatusUnprocessableEntity, validationFailed, "invalid input: "+validationErr.Error(), validationErr.Fields)
 	case errors.As(err, &httpErr):
-		http.Error(w, httpErr.msg, httpErr.code)
-	case errors.Is(err, sql.ErrNoRows) && notFoundMsg != "":
-		http.Error(w, notFoundMsg, http.StatusNotFound)
+		writeError(w, httpErr.code, "", httpErr.msg, nil)
+	case errors.Is(err, database.ErrConflict):
+		writeError(w, http.StatusPreconditionFailed, "", errModified.msg, nil)
+	case errors.Is(err, database.ErrNotFound) && notFoundMsg != "":
+		writeError(w, http.StatusNotFound, "", notFoundMsg, nil)
 	default:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.Error(err.Error())
+		writeError(w, http.StatusInternalServerError, "", err.Error(), nil)
+	}
+}
+
+// writeError writes an error response. Without a code, it is derived from the status, e.g. not_found.
+func writeError(w http.ResponseWriter, status int, code, msg string, details []models.FieldError) {
+	if code == "" {
+		code = strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
 	}
+	writeJSONStatus(w, status, models.ErrorResponse{Code: code, Message: msg, Details: details})
 }
 
 // httpError wraps an HTTP status code and message
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+)
+
+// defaultHistoryLimit is the number of audit events returned if no limit is given
+const defaultHistoryLimit = 100
+
+// DatasetHistoryHandler lists the changes of a dataset and its entries, newest first
+func (h *Handler) DatasetHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if err := h.authorizedHistory(r, datasetId); err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	filter.DatasetId = datasetId
+	events, err := h.Store.ListAuditEvents(filter)
+	handleError(w, err, "")
+	if err == nil {
+		writeJSON(w, events)
+	}
+}
+
+// EntryHistoryHandler lists the changes of an entry, newest first. Deleted entries keep their history.
+func (h *Handler) EntryHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	entryId, err := parseID(r, id, invalidEntryId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	datasetID, err := h.entryHistoryDataset(entryId)
+	if err != nil {
+		handleError(w, err, entryNotFound)
+		return
+	}
+	if err := h.authorizedHistory(r, datasetID); err != nil {
+		handleError(w, err, entryNotFound)
+		return
+	}
+	filter.EntryId = entryId
+	events, err := h.Store.ListAuditEvents(filter)
+	handleError(w, err, "")
+	if err == nil {
+		writeJSON(w, events)
+	}
+}
+
+// entryHistoryDataset returns the dataset of an entry, taken from its history if the entry was deleted
+func (h *Handler) entryHistoryDataset(entryID int) (int, error) {
+	e, err := h.Store.GetEntry(entryID)
+	if err == nil {
+		return e.DatasetId, nil
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return 0, err
+	}
+	latest, err := h.Store.ListAuditEvents(models.AuditFilter{EntryId: entryID, Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+	if len(latest) == 0 {
+		return 0, database.ErrNotFound
+	}
+	return latest[0].DatasetId, nil
+}
+
+// authorizedHistory checks that the authenticated user may read the history of a dataset.
+// The history of a deleted or purged dataset stays readable for its former owner.
+func (h *Handler) authorizedHistory(r *http.Request, datasetID int) error {
+	_, err := h.authorizedDataset(r, datasetID, models.RoleViewer)
+	if !errors.Is(err, database.ErrNotFound) || currentUser(r) == nil {
+		return err
+	}
+	if _, getErr := h.Store.GetDataset(datasetID); !errors.Is(getErr, database.ErrNotFound) {
+		return err
+	}
+	latest, listErr := h.Store.ListAuditEvents(models.AuditFilter{DatasetId: datasetID, Limit: 1})
+	if listErr != nil {
+		return listErr
+	}
+	if len(latest) == 0 || latest[0].Entity != models.AuditDataset ||
+		(latest[0].Action != models.AuditDelete && latest[0].Action != models.AuditPurge) {
+		return err
+	}
+	var deleted models.Dataset
+	if unmarshalErr := json.Unmarshal(latest[0].OldValue, &deleted); unmarshalErr != nil {
+		return unmarshalErr
+	}
+	if deleted.OwnerId != currentUser(r).Id {
+		return err
+	}
+	return nil
+}
+
+// auditedStore returns the store recording the changes of a request in the audit log,
+// attributed to the authenticated user or API key
+func (h *Handler) auditedStore(r *http.Request) database.Store {
+	actor := models.Actor{}
+	if u := currentUser(r); u != nil {
+		actor.UserId, actor.Name = u.Id, u.Username
+	} else if k := currentAPIKey(r); k != nil {
+		actor.APIKeyId, actor.Name = k.Id, "API key "+k.Name
+	}
+	return database.NewAuditedStore(h.Store, actor)
+}
+
+// parseAuditFilter reads the pagination of a history from the query
+func parseAuditFilter(query url.Values) (models.AuditFilter, error) {
+	filter := models.AuditFilter{Limit: defaultHistoryLimit}
+	limit, err := parseOptionalInt(query, "limit", 1, maxPageSize)
+	if err != nil {
+		return filter, err
+	}
+	if limit > 0 {
+		filter.Limit = limit
+	}
+	if filter.Offset, err = parseOptionalInt(query, "offset", 0, math.MaxInt32); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"backend/models"
+	"time"
+)
+
+// minSeasonCorrelation is the autocorrelation a lag needs to be detected as season length
+const minSeasonCorrelation = 0.3
+
+// holtWintersProjector applies additive triple exponential smoothing,
+// which follows a trend as well as a repeating seasonal pattern
+type holtWintersProjector struct{}
+
+type holtWintersForecast struct {
+	level     float64
+	trend     float64
+	seasonals []float64
+	// lastIndex is the position of the last historical entry within the season
+	lastIndex    int
+	seasonLength int
+	residuals    []float64
+}
+
+func (holtWintersProjector) Info() models.ProjectorInfo {
+	return models.ProjectorInfo{
+		Name:        "holtWinters",
+		Description: "Holt-Winters triple exponential smoothing for datasets with a repeating seasonal pattern",
+		Params: []models.ProjectorParam{
+			{Name: "alpha", Type: paramTypeNumber, Default: 0.5, Min: 0, Max: 1, Description: "Smoothing factor for the level"},
+			{Name: "beta", Type: paramTypeNumber, Default: 0.1, Min: 0, Max: 1, Description: "Smoothing factor for the trend"},
+			{Name: "gamma", Type: paramTypeNumber, Default: 0.3, Min: 0, Max: 1, Description: "Smoothing factor for the seasonal pattern"},
+			{Name: "seasonLength", Type: paramTypeInteger, Default: 0, Min: 0, Max: 366,
+				Description: "Number of entries per season, 0 detects it from the entries"},
+		},
+	}
+}
+
+func (holtWintersProjector) Fit(entries []models.Entry, params map[string]float64) Forecast {
+	alpha, beta, gamma := params["alpha"], params["beta"], params["gamma"]
+	seasonLength := int(params["seasonLength"])
+	if seasonLength == 0 {
+		seasonLength = detectSeasonLength(entries)
+	}
+	// Initialising the seasonal pattern needs two full seasons, otherwise fall back to no seasonality
+	if seasonLength < 2 || len(entries) < 2*seasonLength {
+		seasonLength = 1
+	}
+
+	f := holtWintersForecast{seasonLength: seasonLength, seasonals: make([]float64, seasonLength)}
+	start := 1
+	if seasonLength == 1 {
+		f.level = entries[0].Value
+		f.trend = entries[1].Value - entries[0].Value
+	} else {
+		start = seasonLength
+		firstMean := meanValue(entries[:seasonLength])
+		secondMean := meanValue(entries[seasonLength : 2*seasonLength])
+		f.trend = (secondMean - firstMean) / float64(seasonLength)
+		// The mean is the level at the middle of the first season. Smoothing continues after its last entry,
+		// so the level is moved forward to it and the seasonal components are taken relative to the trend line.
+		middle := float64(seasonLength-1) / 2
+		f.level = firstMean + f.trend*middle
+		for i := 0; i < seasonLength; i++ {
+			f.seasonals[i] = entries[i].Value - (firstMean + f.trend*(float64(i)-middle))
+		}
+	}
+
+	f.residuals = make([]float64, 0, len(entries)-start)
+	for t := start; t < len(entries); t++ {
+		y := entries[t].Value
+		s := t % seasonLength
+		// One-step-ahead error of the forecast made before seeing the entry
+		f.residuals = append(f.residuals, y-(f.level+f.trend+f.seasonals[s]))
+
+		prevLevel := f.level
+		f.level = alpha*(y-f.seasonals[s]) + (1-alpha)*(f.level+f.trend)
+		f.trend = beta*(f.level-prevLevel) + (1-beta)*f.trend
+		if seasonLength > 1 {
+			f.seasonals[s] = gamma*(y-f.level) + (1-gamma)*f.seasonals[s]
+		}
+	}
+	f.lastIndex = (len(entries) - 1) % seasonLength
+
+	return f
+}
+
+func (f holtWintersForecast) ValueAt(step int, _ time.Time) float64 {
+	return f.level + float64(step)*f.trend + f.seasonals[(f.lastIndex+step)%f.seasonLength]
+}
+
+func (f holtWintersForecast) Trend() float64 { return f.trend }
+
+func (f holtWintersForecast) Residuals() []float64 { return f.residuals }
+
+func (f holtWintersForecast) FittedParams() map[string]float64 {
+	return map[string]float64{"seasonLength": float64(f.seasonLength)}
+}
+
+// detectSeasonLength returns the lag with the strongest autocorrelation between the changes of consecutive entries.
+// Differencing removes the trend first, so it does not dominate the correlation.
+// Returns 0 if no lag correlates strongly enough to be considered seasonal.
+func detectSeasonLength(entries []models.Entry) int {
+	changes := make([]float64, 0, len(entries)-1)
+	for i := 1; i < len(entries); i++ {
+		changes = append(changes, entries[i].Value-entries[i-1].Value)
+	}
+
+	var mean float64
+	for _, c := range changes {
+		mean += c
+	}
+	mean /= float64(len(changes))
+
+	var variance float64
+	for _, c := range changes {
+		variance += (c - mean) * (c - mean)
+	}
+	if variance == 0 {
+		return 0
+	}
+
+	best, bestCorrelation := 0, minSeasonCorrelation
+	for lag := 2; lag <= len(changes)/2; lag++ {
+		var covariance float64
+		for i := lag; i < len(changes); i++ {
+			covariance += (changes[i] - mean) * (changes[i-lag] - mean)
+		}
+		if correlation := covariance / variance; correlation > bestCorrelation {
+			best, bestCorrelation = lag, correlation
+		}
+	}
+	return best
+}
+
+// meanValue returns the average value of entries
+func meanValue(entries []models.Entry) float64 {
+	var sum float64
+	for _, e := range entries {
+		sum += e.Value
+	}
+	return sum / float64(len(entries))
+}
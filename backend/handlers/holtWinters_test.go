@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"backend/models"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHoltWintersProjectsLinearSeasonalSeriesExactly(t *testing.T) {
+	const base, slope = 10.0, 2.0
+	seasonal := []float64{3, -1, -2, 0}
+	valueAt := func(i int) float64 { return base + slope*float64(i) + seasonal[i%len(seasonal)] }
+
+	origin := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := make([]models.Entry, 12)
+	for i := range entries {
+		entries[i] = models.Entry{Value: valueAt(i), Date: origin.AddDate(0, i, 0)}
+	}
+	params := map[string]float64{"alpha": 0.5, "beta": 0.1, "gamma": 0.3, "seasonLength": float64(len(seasonal))}
+
+	forecast := holtWintersProjector{}.Fit(entries, params)
+
+	for i, r := range forecast.Residuals() {
+		if math.Abs(r) > 1e-9 {
+			t.Errorf("residual %d = %g, want 0", i, r)
+		}
+	}
+	if got := forecast.Trend(); math.Abs(got-slope) > 1e-9 {
+		t.Errorf("trend = %g, want %g", got, slope)
+	}
+	last := len(entries) - 1
+	for step := 1; step <= 8; step++ {
+		want := valueAt(last + step)
+		if got := forecast.ValueAt(step, origin.AddDate(0, last+step, 0)); math.Abs(got-want) > 1e-9 {
+			t.Errorf("step %d = %g, want %g", step, got, want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/validation"
+	"errors"
+	"net/http"
+)
+
+const (
+	userId         = "userId"
+	invalidUserId  = "invalid user id"
+	memberNotFound = "member not found"
+)
+
+// ListMembersHandler lists the owner, if the dataset has one, and the members of a dataset
+func (h *Handler) ListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	d, err := h.authorizedDataset(r, datasetId, models.RoleViewer)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	members, err := h.Store.ListDatasetMembers(datasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+
+	// Datasets created before user accounts existed have no owner until the first user registers
+	list := []models.DatasetMember{}
+	owner, err := h.Store.GetUser(d.OwnerId)
+	switch {
+	case err == nil:
+		list = append(list, models.DatasetMember{DatasetId: d.Id, UserId: owner.Id, Username: owner.Username, Role: models.RoleOwner})
+	case !errors.Is(err, database.ErrNotFound):
+		handleError(w, err, "")
+		return
+	}
+	writeJSON(w, append(list, members...))
+}
+
+// AddMemberHandler shares a dataset with a user as editor or viewer, or changes the role of a member.
+// Responds with 201 Created for a new member and 200 OK for a changed role.
+func (h *Handler) AddMemberHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	var invite models.MemberInvite
+	if err := decodeJSON(r, &invite); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	d, err := h.authorizedDataset(r, datasetId, models.RoleOwner)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	if err := validation.MemberInvite(&invite); err != nil {
+		handleError(w, err, "")
+		return
+	}
+
+	u, err := h.Store.FindUserByUsername(invite.Username)
+	if errors.Is(err, database.ErrNotFound) {
+		handleError(w, &validation.Error{Fields: []models.FieldError{{Field: "username", Message: "does not exist"}}}, "")
+		return
+	}
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	if u.Id == d.OwnerId {
+		handleError(w, &httpError{http.StatusConflict, "the owner of a dataset cannot be given another role"}, "")
+		return
+	}
+
+	status := http.StatusOK
+	if _, err := h.Store.GetDatasetMember(datasetId, u.Id); errors.Is(err, database.ErrNotFound) {
+		status = http.StatusCreated
+	} else if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	m := models.DatasetMember{DatasetId: datasetId, UserId: u.Id, Username: u.Username, Role: invite.Role}
+	if err := h.Store.SetDatasetMember(&m); err != nil {
+		handleError(w, err, "")
+		return
+	}
+	writeJSONStatus(w, status, m)
+}
+
+// RemoveMemberHandler revokes the access of a member to a dataset.
+// The owner may remove any member, every member may remove themselves.
+func (h *Handler) RemoveMemberHandler(w http.ResponseWriter, r *http.Request) {
+	datasetId, err := parseID(r, id, invalidDatasetId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	memberId, err := parseID(r, userId, invalidUserId)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	role := models.RoleOwner
+	if memberId == currentUser(r).Id {
+		role = models.RoleViewer
+	}
+	d, err := h.authorizedDataset(r, datasetId, role)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	if memberId == d.OwnerId {
+		handleError(w, &httpError{http.StatusConflict, "the owner cannot be removed from a dataset"}, "")
+		return
+	}
+	if err := h.Store.DeleteDatasetMember(datasetId, memberId); err != nil {
+		handleError(w, err, memberNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestListMembersOfLegacyDatasetWithoutOwner(t *testing.T) {
+	h, u, _ := newTestHandler(t)
+	legacyID, err := h.Store.CreateDataset(&models.Dataset{Name: "legacy", Kind: models.KindCumulative})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Store.SetDatasetMember(&models.DatasetMember{DatasetId: legacyID, UserId: u.Id, Role: models.RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/datasets/1/members", nil)
+	w := httptest.NewRecorder()
+
+	h.ListMembersHandler(w, requestAs(r, u, map[string]string{id: strconv.Itoa(legacyID)}))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var members []models.DatasetMember
+	if err := json.Unmarshal(w.Body.Bytes(), &members); err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].UserId != u.Id || members[0].Role != models.RoleViewer {
+		t.Errorf("got %+v, want only the viewer", members)
+	}
+}
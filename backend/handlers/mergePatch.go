@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// applyMergePatch applies a JSON Merge Patch (RFC 7386) to target, a pointer to a model.
+// Members of the patch replace those of target, null removes them, which resets the field to its zero value.
+// Nested objects are merged recursively, all other values replace the existing ones as a whole.
+func applyMergePatch(target any, patch json.RawMessage) error {
+	var patchDoc any
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return &httpError{http.StatusBadRequest, "invalid merge patch: " + err.Error()}
+	}
+	if _, ok := patchDoc.(map[string]any); !ok {
+		return &httpError{http.StatusBadRequest, "invalid merge patch, expected a JSON object"}
+	}
+
+	current, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, patchDoc))
+	if err != nil {
+		return err
+	}
+	// Decode into a zeroed target, so members removed by the patch reset their field
+	reflect.ValueOf(target).Elem().SetZero()
+	if err := json.Unmarshal(merged, target); err != nil {
+		return &httpError{http.StatusBadRequest, "invalid merge patch: " + err.Error()}
+	}
+	return nil
+}
+
+// mergePatch merges patch into doc as described in RFC 7386
+func mergePatch(doc, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	docObject, ok := doc.(map[string]any)
+	if !ok {
+		docObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(docObject, key)
+		} else {
+			docObject[key] = mergePatch(docObject[key], value)
+		}
+	}
+	return docObject
+}
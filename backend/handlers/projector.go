@@ -2,42 +2,298 @@ package handlers
 
 import (
 	"backend/models"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
 	"time"
 )
 
-// ProjectUntilTarget keeps projecting until targetValue is reached.
-// Stops at 0 if avgChange overshoot into negative values forever.
-func ProjectUntilTarget(dataset models.Dataset, entries []models.Entry) []models.Entry {
-	if dataset.TargetValue == nil || len(entries) < 2 {
-		return entries
+const (
+	untilTarget  = "target"
+	untilEndDate = "endDate"
+	untilDate    = "date"
+
+	paramTypeNumber  = "number"
+	paramTypeInteger = "integer"
+
+	defaultConfidence = 0.95
+
+	// maxProjectionSteps caps the number of projected entries, so a tiny trend or a distant
+	// end date cannot produce an unbounded response
+	maxProjectionSteps = 5000
+)
+
+// Projector is a forecasting strategy that can be selected by name
+type Projector interface {
+	// Info describes the projector and the parameters it accepts
+	Info() models.ProjectorInfo
+	// Fit builds a forecast from entries sorted by date, using resolved parameters
+	Fit(entries []models.Entry, params map[string]float64) Forecast
+}
+
+// Forecast predicts the values of future projected entries
+type Forecast interface {
+	// ValueAt returns the value of the step-th projected entry dated at date.
+	// Step 0 is the forecast level at the last historical entry.
+	ValueAt(step int, date time.Time) float64
+	// Trend returns the direction the forecast is heading in
+	Trend() float64
+	// Residuals returns the in-sample errors of the forecast on the historical entries
+	Residuals() []float64
+}
+
+// fittedParamsForecast is implemented by forecasts that derive parameters from the entries while fitting
+type fittedParamsForecast interface {
+	FittedParams() map[string]float64
+}
+
+// regressionForecast is implemented by forecasts that are backed by a linear regression
+type regressionForecast interface {
+	Regression() models.Regression
+}
+
+var projectors = map[string]Projector{}
+
+func init() {
+	RegisterProjector(averageProjector{})
+	RegisterProjector(linearProjector{})
+	RegisterProjector(exponentialSmoothingProjector{})
+	RegisterProjector(movingAverageProjector{})
+	RegisterProjector(holtWintersProjector{})
+}
+
+// RegisterProjector makes a projector available under its name
+func RegisterProjector(p Projector) {
+	projectors[p.Info().Name] = p
+}
+
+// GetProjector returns the projector registered under name
+func GetProjector(name string) (Projector, bool) {
+	p, ok := projectors[name]
+	return p, ok
+}
+
+// ListProjectors returns the info of all registered projectors sorted by name
+func ListProjectors() []models.ProjectorInfo {
+	infos := make([]models.ProjectorInfo, 0, len(projectors))
+	for _, p := range projectors {
+		infos = append(infos, p.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Project fits projector to the entries and extends them until the target, the end date or an explicit date.
+// The targetValue, untilDate and fromDate query parameters override the dataset for this projection only.
+// Incremental datasets are projected as running totals, so the target applies to the accumulated value.
+// Entries are returned unchanged if there is not enough history or the dataset lacks the required field.
+func Project(dataset models.Dataset, entries []models.Entry, projector Projector, until string, query url.Values,
+) (models.Projection, error) {
+	info := projector.Info()
+	params, err := resolveParams(info.Params, query)
+	if err != nil {
+		return models.Projection{}, err
+	}
+	confidence, err := parseConfidence(query)
+	if err != nil {
+		return models.Projection{}, err
+	}
+	loc, err := parseTimeZone(query)
+	if err != nil {
+		return models.Projection{}, err
+	}
+	// The cadence is checked before it is needed, so the parameters are validated even without enough history
+	if _, err := parseCadence(query); err != nil {
+		return models.Projection{}, err
+	}
+	if dataset.Kind == models.KindIncremental {
+		entries = runningTotals(entries)
+	}
+	dataset, entries, err = applyOverrides(dataset, entries, query, loc)
+	if err != nil {
+		return models.Projection{}, err
+	}
+
+	var endDate *time.Time
+	switch until {
+	case untilTarget:
+	case untilEndDate:
+		endDate = dataset.EndDate
+	case untilDate:
+		if query.Get("untilDate") == "" {
+			return models.Projection{}, &httpError{http.StatusBadRequest, "missing untilDate"}
+		}
+		endDate = dataset.EndDate
+	default:
+		return models.Projection{}, &httpError{http.StatusBadRequest, "invalid until, expected target, endDate or date"}
+	}
+
+	projection := models.Projection{
+		Method:      info.Name,
+		Until:       until,
+		Params:      params,
+		Confidence:  confidence,
+		TimeZone:    loc.String(),
+		TargetValue: dataset.TargetValue,
+		EndDate:     endDate,
+		Entries:     entries,
+	}
+	if len(entries) < 2 || (until == untilTarget && dataset.TargetValue == nil) || (until != untilTarget && endDate == nil) {
+		return projection, nil
 	}
 
 	sortedEntries := sortEntriesByDate(entries)
-	wrapped := sortedEntries
+	forecast := projector.Fit(sortedEntries, params)
+	if rf, ok := forecast.(regressionForecast); ok {
+		regression := rf.Regression()
+		projection.Regression = &regression
+	}
+	if fp, ok := forecast.(fittedParamsForecast); ok {
+		for name, value := range fp.FittedParams() {
+			projection.Params[name] = value
+		}
+	}
 
-	avgChange, avgStep := calcAverageChange(sortedEntries)
-	if avgChange == 0 {
-		return wrapped
+	c, err := resolveCadence(sortedEntries, query)
+	if err != nil {
+		return models.Projection{}, err
 	}
+	projection.Cadence = c.name
+	c = c.anchoredTo(sortedEntries, loc)
 
-	last := sortedEntries[len(sortedEntries)-1]
-	return appendProjectionsUntilTarget(dataset.Id, wrapped, last, avgChange, avgStep, *dataset.TargetValue)
+	// Calendar steps are taken in the requested time zone, starting from the last entry
+	origin := sortedEntries[len(sortedEntries)-1].Date.In(loc)
+	if until == untilTarget {
+		projection.Entries, projection.Truncated = appendProjectionsUntilTarget(dataset.Id, sortedEntries, origin, forecast, c,
+			*dataset.TargetValue)
+	} else {
+		projection.Entries, projection.Truncated = appendProjectionsUntilEndDate(dataset.Id, sortedEntries, origin, forecast, c,
+			*endDate)
+	}
+
+	projection.StdError = rootMeanSquare(forecast.Residuals())
+	applyPredictionBands(projection.Entries[len(sortedEntries):], projection.StdError, confidence)
+	return projection, nil
 }
 
-// ProjectUntilEndDate projects until dataset.EndDate (if present)
-func ProjectUntilEndDate(dataset models.Dataset, entries []models.Entry) []models.Entry {
-	if dataset.EndDate == nil || len(entries) < 2 {
-		return entries
+// applyOverrides replaces the target value and end date of the dataset with the targetValue and untilDate
+// query parameters and drops entries before fromDate. The caller's dataset and entries are not modified.
+func applyOverrides(dataset models.Dataset, entries []models.Entry, query url.Values, loc *time.Location,
+) (models.Dataset, []models.Entry, error) {
+	if raw := query.Get("targetValue"); raw != "" {
+		target, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(target) || math.IsInf(target, 0) {
+			return dataset, nil, &httpError{http.StatusBadRequest, "invalid targetValue, expected a number"}
+		}
+		dataset.TargetValue = &target
 	}
 
-	sortedEntries := sortEntriesByDate(entries)
-	wrapped := sortedEntries
+	if query.Get("untilDate") != "" {
+		date, err := parseDateParam(query, "untilDate", loc)
+		if err != nil {
+			return dataset, nil, err
+		}
+		dataset.EndDate = &date
+	}
+
+	if query.Get("fromDate") != "" {
+		from, err := parseDateParam(query, "fromDate", loc)
+		if err != nil {
+			return dataset, nil, err
+		}
+		recent := make([]models.Entry, 0, len(entries))
+		for _, e := range entries {
+			if !e.Date.Before(from) {
+				recent = append(recent, e)
+			}
+		}
+		entries = recent
+	}
+
+	return dataset, entries, nil
+}
+
+// parseConfidence reads the confidence level of the prediction bands from the query
+func parseConfidence(query url.Values) (float64, error) {
+	raw := query.Get("confidence")
+	if raw == "" {
+		return defaultConfidence, nil
+	}
+	confidence, err := strconv.ParseFloat(raw, 64)
+	if err != nil || !(confidence > 0 && confidence < 1) {
+		return 0, &httpError{http.StatusBadRequest, "invalid confidence, expected a number between 0 and 1"}
+	}
+	return confidence, nil
+}
 
-	avgChange, avgStep := calcAverageChange(sortedEntries)
-	last := sortedEntries[len(sortedEntries)-1]
+// applyPredictionBands sets lower and upper bounds on projected entries.
+// The band widens with the square root of the number of steps ahead, as forecast errors accumulate.
+func applyPredictionBands(projected []models.Entry, stdError, confidence float64) {
+	z := math.Sqrt2 * math.Erfinv(confidence)
+	for i := range projected {
+		margin := z * stdError * math.Sqrt(float64(i+1))
+		lower := projected[i].Value - margin
+		upper := projected[i].Value + margin
+		projected[i].Lower = &lower
+		projected[i].Upper = &upper
+	}
+}
+
+// rootMeanSquare returns the root mean square of residuals, or 0 if there are none
+func rootMeanSquare(residuals []float64) float64 {
+	if len(residuals) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, r := range residuals {
+		sum += r * r
+	}
+	return math.Sqrt(sum / float64(len(residuals)))
+}
+
+// resolveParams reads projector parameters from the query, falling back to their defaults
+func resolveParams(specs []models.ProjectorParam, query url.Values) (map[string]float64, error) {
+	params := make(map[string]float64, len(specs))
+	for _, spec := range specs {
+		raw := query.Get(spec.Name)
+		if raw == "" {
+			params[spec.Name] = spec.Default
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(value) {
+			return nil, &httpError{http.StatusBadRequest, fmt.Sprintf("invalid %s: expected a %s", spec.Name, spec.Type)}
+		}
+		if spec.Type == paramTypeInteger && value != math.Trunc(value) {
+			return nil, &httpError{http.StatusBadRequest, fmt.Sprintf("invalid %s: expected an integer", spec.Name)}
+		}
+		if value < spec.Min || value > spec.Max {
+			return nil, &httpError{http.StatusBadRequest,
+				fmt.Sprintf("invalid %s: must be between %g and %g", spec.Name, spec.Min, spec.Max)}
+		}
+		params[spec.Name] = value
+	}
+	return params, nil
+}
 
-	return appendProjectionsUntilEndDate(dataset.Id, wrapped, last, avgChange, avgStep, *dataset.EndDate)
+// parseDateParam parses a required RFC 3339 or YYYY-MM-DD date from the query.
+// Dates without a time are taken as midnight in loc.
+func parseDateParam(query url.Values, key string, loc *time.Location) (time.Time, error) {
+	raw := query.Get(key)
+	if raw == "" {
+		return time.Time{}, &httpError{http.StatusBadRequest, "missing " + key}
+	}
+	if date, err := time.Parse(time.RFC3339, raw); err == nil {
+		return date, nil
+	}
+	date, err := time.ParseInLocation(time.DateOnly, raw, loc)
+	if err != nil {
+		return time.Time{}, &httpError{http.StatusBadRequest, "invalid " + key + ", expected RFC 3339 or YYYY-MM-DD"}
+	}
+	return date, nil
 }
 
 // sortEntriesByDate returns a sorted copy of entries by Date
@@ -48,56 +304,69 @@ func sortEntriesByDate(entries []models.Entry) []models.Entry {
 	return sorted
 }
 
-// appendProjectionsUntilTarget appends projected entries until targetValue is reached
-func appendProjectionsUntilTarget(datasetID int, wrapped []models.Entry, last models.Entry,
-	avgChange float64, avgStep time.Duration, target float64) []models.Entry {
-	value := last.Value
-	nextDate := last.Date.Add(avgStep)
+// runningTotals returns a copy of entries sorted by date, with each value replaced by the sum of all values up to it
+func runningTotals(entries []models.Entry) []models.Entry {
+	totals := sortEntriesByDate(entries)
+	var sum float64
+	for i := range totals {
+		sum += totals[i].Value
+		totals[i].Value = sum
+	}
+	return totals
+}
+
+// appendProjectionsUntilTarget appends projected entries until targetValue is reached.
+// Stops at 0 if a falling forecast would overshoot into negative values forever.
+// Reports whether projecting stopped at maxProjectionSteps before reaching the target.
+func appendProjectionsUntilTarget(datasetID int, wrapped []models.Entry, origin time.Time,
+	forecast Forecast, c cadence, target float64) ([]models.Entry, bool) {
+	trend := forecast.Trend()
+	if trend == 0 || !c.valid() {
+		return wrapped, false
+	}
+
+	value := forecast.ValueAt(0, origin)
 
-	for {
-		if (avgChange > 0 && value >= target) || (avgChange < 0 && (value <= target || value <= 0)) {
+	for step := 1; ; step++ {
+		if (trend > 0 && value >= target) || (trend < 0 && (value <= target || value <= 0)) {
 			break
 		}
+		if step > maxProjectionSteps {
+			return wrapped, true
+		}
 
-		value += avgChange
-		if avgChange < 0 && value < 0 {
+		nextDate := c.dateAt(origin, step)
+		value = forecast.ValueAt(step, nextDate)
+		if trend < 0 && value < 0 {
 			value = 0
 		}
 
-		wrapped = append(wrapped, models.Entry{
-			Id:        0,
-			DatasetId: datasetID,
-			Value:     value,
-			Label:     "Projected",
-			Date:      nextDate,
-			Projected: true,
-		})
-		nextDate = nextDate.Add(avgStep)
+		wrapped = append(wrapped, projectedEntry(datasetID, value, nextDate))
 	}
 
-	return wrapped
+	return wrapped, false
 }
 
-// appendProjectionsUntilEndDate appends projected entries until endDate is reached
-func appendProjectionsUntilEndDate(datasetID int, wrapped []models.Entry, last models.Entry,
-	avgChange float64, avgStep time.Duration, endDate time.Time) []models.Entry {
-	value := last.Value
-	nextDate := last.Date.Add(avgStep)
+// appendProjectionsUntilEndDate appends projected entries until endDate is reached.
+// Reports whether projecting stopped at maxProjectionSteps before reaching endDate.
+func appendProjectionsUntilEndDate(datasetID int, wrapped []models.Entry, origin time.Time,
+	forecast Forecast, c cadence, endDate time.Time) ([]models.Entry, bool) {
+	if !c.valid() {
+		return wrapped, false
+	}
 
-	for !nextDate.After(endDate) {
-		value += avgChange
-		wrapped = append(wrapped, models.Entry{
-			Id:        0,
-			DatasetId: datasetID,
-			Value:     value,
-			Label:     "Projected",
-			Date:      nextDate,
-			Projected: true,
-		})
-		nextDate = nextDate.Add(avgStep)
+	for step := 1; ; step++ {
+		nextDate := c.dateAt(origin, step)
+		if nextDate.After(endDate) {
+			break
+		}
+		if step > maxProjectionSteps {
+			return wrapped, true
+		}
+		wrapped = append(wrapped, projectedEntry(datasetID, forecast.ValueAt(step, nextDate), nextDate))
 	}
 
-	return wrapped
+	return wrapped, false
 }
 
 // calcAverageChange computes the avg value delta and avg time step
@@ -117,3 +386,20 @@ func calcAverageChange(entries []models.Entry) (float64, time.Duration) {
 	avgStep := time.Duration(int64(totalTime) / int64(len(entries)-1))
 	return avgChange, avgStep
 }
+
+// daysBetween returns the fractional number of days from start to end
+func daysBetween(start, end time.Time) float64 {
+	return end.Sub(start).Hours() / 24
+}
+
+// projectedEntry builds a projected entry for a dataset
+func projectedEntry(datasetID int, value float64, date time.Time) models.Entry {
+	return models.Entry{
+		Id:        0,
+		DatasetId: datasetID,
+		Value:     value,
+		Label:     "Projected",
+		Date:      date,
+		Projected: true,
+	}
+}
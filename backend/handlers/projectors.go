@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"backend/models"
+	"time"
+)
+
+// averageProjector continues the average change between all consecutive entries
+type averageProjector struct{}
+
+type averageForecast struct {
+	last      float64
+	avgChange float64
+	residuals []float64
+}
+
+func (averageProjector) Info() models.ProjectorInfo {
+	return models.ProjectorInfo{
+		Name:        "average",
+		Description: "Continues the average change between consecutive entries",
+		Params:      []models.ProjectorParam{},
+	}
+}
+
+func (averageProjector) Fit(entries []models.Entry, _ map[string]float64) Forecast {
+	avgChange, _ := calcAverageChange(entries)
+	return averageForecast{
+		last:      entries[len(entries)-1].Value,
+		avgChange: avgChange,
+		residuals: changeResiduals(entries, avgChange),
+	}
+}
+
+func (f averageForecast) ValueAt(step int, _ time.Time) float64 {
+	return f.last + float64(step)*f.avgChange
+}
+
+func (f averageForecast) Trend() float64 { return f.avgChange }
+
+func (f averageForecast) Residuals() []float64 { return f.residuals }
+
+// changeResiduals returns how far each change between consecutive entries deviates from avgChange
+func changeResiduals(entries []models.Entry, avgChange float64) []float64 {
+	residuals := make([]float64, 0, len(entries)-1)
+	for i := 1; i < len(entries); i++ {
+		residuals = append(residuals, entries[i].Value-entries[i-1].Value-avgChange)
+	}
+	return residuals
+}
+
+// linearProjector follows a least-squares regression line of value over time
+type linearProjector struct{}
+
+type linearForecast struct {
+	origin     time.Time
+	regression models.Regression
+	residuals  []float64
+}
+
+func (linearProjector) Info() models.ProjectorInfo {
+	return models.ProjectorInfo{
+		Name:        "linear",
+		Description: "Follows a least-squares regression line of value over time",
+		Params:      []models.ProjectorParam{},
+	}
+}
+
+func (linearProjector) Fit(entries []models.Entry, _ map[string]float64) Forecast {
+	f := linearForecast{origin: entries[0].Date, regression: calcLinearRegression(entries)}
+	f.residuals = make([]float64, 0, len(entries))
+	for _, e := range entries {
+		f.residuals = append(f.residuals, e.Value-f.ValueAt(0, e.Date))
+	}
+	return f
+}
+
+func (f linearForecast) ValueAt(_ int, date time.Time) float64 {
+	return f.regression.Intercept + f.regression.Slope*daysBetween(f.origin, date)
+}
+
+func (f linearForecast) Trend() float64 { return f.regression.Slope }
+
+func (f linearForecast) Residuals() []float64 { return f.residuals }
+
+func (f linearForecast) Regression() models.Regression { return f.regression }
+
+// calcLinearRegression fits value = intercept + slope * days with least squares,
+// where days is the time elapsed since the first entry.
+// The slope is therefore the value change per day and the intercept the fitted value at the first entry.
+func calcLinearRegression(entries []models.Entry) models.Regression {
+	if len(entries) == 0 {
+		return models.Regression{}
+	}
+
+	n := float64(len(entries))
+	origin := entries[0].Date
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, e := range entries {
+		x := daysBetween(origin, e.Date)
+		sumX += x
+		sumY += e.Value
+		sumXY += x * e.Value
+		sumXX += x * x
+	}
+
+	meanY := sumY / n
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		// All entries share the same date, so there is no trend to fit
+		return models.Regression{Intercept: meanY}
+	}
+
+	slope := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - slope*sumX) / n
+
+	var ssRes, ssTot float64
+	for _, e := range entries {
+		fitted := intercept + slope*daysBetween(origin, e.Date)
+		ssRes += (e.Value - fitted) * (e.Value - fitted)
+		ssTot += (e.Value - meanY) * (e.Value - meanY)
+	}
+
+	rSquared := 1.0
+	if ssTot != 0 {
+		rSquared = 1 - ssRes/ssTot
+	}
+
+	return models.Regression{Slope: slope, Intercept: intercept, RSquared: rSquared}
+}
+
+// exponentialSmoothingProjector applies Holt's double exponential smoothing,
+// which weights recent entries more heavily than older ones
+type exponentialSmoothingProjector struct{}
+
+type exponentialSmoothingForecast struct {
+	level     float64
+	trend     float64
+	residuals []float64
+}
+
+func (exponentialSmoothingProjector) Info() models.ProjectorInfo {
+	return models.ProjectorInfo{
+		Name:        "exponentialSmoothing",
+		Description: "Holt's double exponential smoothing, weighting recent entries more heavily",
+		Params: []models.ProjectorParam{
+			{Name: "alpha", Type: paramTypeNumber, Default: 0.5, Min: 0, Max: 1, Description: "Smoothing factor for the level"},
+			{Name: "beta", Type: paramTypeNumber, Default: 0.3, Min: 0, Max: 1, Description: "Smoothing factor for the trend"},
+		},
+	}
+}
+
+func (exponentialSmoothingProjector) Fit(entries []models.Entry, params map[string]float64) Forecast {
+	alpha, beta := params["alpha"], params["beta"]
+
+	level := entries[0].Value
+	trend := entries[1].Value - entries[0].Value
+	residuals := make([]float64, 0, len(entries)-1)
+	for _, e := range entries[1:] {
+		// One-step-ahead error of the forecast made before seeing e
+		residuals = append(residuals, e.Value-(level+trend))
+		prevLevel := level
+		level = alpha*e.Value + (1-alpha)*(level+trend)
+		trend = beta*(level-prevLevel) + (1-beta)*trend
+	}
+
+	return exponentialSmoothingForecast{level: level, trend: trend, residuals: residuals}
+}
+
+func (f exponentialSmoothingForecast) ValueAt(step int, _ time.Time) float64 {
+	return f.level + float64(step)*f.trend
+}
+
+func (f exponentialSmoothingForecast) Trend() float64 { return f.trend }
+
+func (f exponentialSmoothingForecast) Residuals() []float64 { return f.residuals }
+
+// movingAverageProjector continues the average change over the most recent entries only
+type movingAverageProjector struct{}
+
+func (movingAverageProjector) Info() models.ProjectorInfo {
+	return models.ProjectorInfo{
+		Name:        "movingAverage",
+		Description: "Continues the average change over the most recent entries",
+		Params: []models.ProjectorParam{
+			{Name: "window", Type: paramTypeInteger, Default: 3, Min: 1, Max: 1000, Description: "Number of most recent changes to average"},
+		},
+	}
+}
+
+func (movingAverageProjector) Fit(entries []models.Entry, params map[string]float64) Forecast {
+	window := int(params["window"])
+	if window > len(entries)-1 {
+		window = len(entries) - 1
+	}
+
+	recent := entries[len(entries)-1-window:]
+	avgChange, _ := calcAverageChange(recent)
+	return averageForecast{
+		last:      entries[len(entries)-1].Value,
+		avgChange: avgChange,
+		residuals: changeResiduals(recent, avgChange),
+	}
+}
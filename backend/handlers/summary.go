@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"backend/models"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	targetStatusReached   = "reached"
+	targetStatusProjected = "projected"
+	targetStatusNever     = "never"
+)
+
+// Summarize condenses the projections of a dataset into the figures needed to track its target.
+// It accepts the same query parameters as Project.
+func Summarize(dataset models.Dataset, entries []models.Entry, projector Projector, query url.Values,
+) (models.ForecastSummary, error) {
+	toTarget, err := Project(dataset, entries, projector, untilTarget, query)
+	if err != nil {
+		return models.ForecastSummary{}, err
+	}
+	toEndDate, err := Project(dataset, entries, projector, untilEndDate, query)
+	if err != nil {
+		return models.ForecastSummary{}, err
+	}
+
+	summary := models.ForecastSummary{
+		DatasetId:          dataset.Id,
+		Method:             toTarget.Method,
+		Cadence:            toTarget.Cadence,
+		TargetValue:        toTarget.TargetValue,
+		EndDate:            toEndDate.EndDate,
+		MaxProjectionSteps: maxProjectionSteps,
+	}
+
+	history := sortEntriesByDate(actualEntries(toTarget.Entries))
+	summary.TargetStatus, summary.TargetDate, summary.Reason = estimateTarget(toTarget, history)
+	if len(history) == 0 {
+		return summary, nil
+	}
+
+	first, last := history[0], history[len(history)-1]
+	summary.LastValue = &last.Value
+	summary.LastDate = &last.Date
+
+	if summary.TargetValue != nil {
+		progress := 100.0
+		if *summary.TargetValue != first.Value {
+			progress = (last.Value - first.Value) / (*summary.TargetValue - first.Value) * 100
+		}
+		summary.Progress = &progress
+	}
+
+	if summary.EndDate == nil {
+		return summary, nil
+	}
+
+	projectedToEndDate := toEndDate.Entries[len(history):]
+	switch {
+	case toEndDate.Truncated:
+	case len(projectedToEndDate) > 0:
+		summary.ValueAtEndDate = &projectedToEndDate[len(projectedToEndDate)-1].Value
+	case !summary.EndDate.Before(last.Date):
+		summary.ValueAtEndDate = &last.Value
+	}
+
+	if summary.TargetValue != nil {
+		if days := daysBetween(last.Date, *summary.EndDate); days > 0 {
+			rate := 0.0
+			if summary.TargetStatus != targetStatusReached {
+				rate = (*summary.TargetValue - last.Value) / days
+			}
+			summary.RequiredRatePerDay = &rate
+		}
+	}
+
+	return summary, nil
+}
+
+// estimateTarget determines whether and when the target of a projection is reached.
+// The reason explains why the target is never reached.
+func estimateTarget(projection models.Projection, history []models.Entry) (string, *time.Time, string) {
+	if projection.TargetValue == nil {
+		return targetStatusNever, nil, "dataset has no target value"
+	}
+	if len(history) == 0 {
+		return targetStatusNever, nil, "dataset has no entries"
+	}
+
+	target := *projection.TargetValue
+	start := history[0].Value
+	for _, e := range history {
+		if targetReached(start, e.Value, target) {
+			return targetStatusReached, &e.Date, ""
+		}
+	}
+
+	if len(history) < 2 {
+		return targetStatusNever, nil, "at least two entries are needed to project"
+	}
+	if projection.Truncated {
+		return targetStatusNever, nil, fmt.Sprintf("target is not reached within %d projected entries", maxProjectionSteps)
+	}
+
+	projected := projection.Entries[len(history):]
+	if len(projected) > 0 {
+		last := projected[len(projected)-1]
+		if targetReached(start, last.Value, target) {
+			return targetStatusProjected, &last.Date, ""
+		}
+		if last.Value == 0 {
+			return targetStatusNever, nil, "forecast levels off at zero before reaching the target"
+		}
+	}
+	return targetStatusNever, nil, "forecast does not move towards the target"
+}
+
+// targetReached reports whether value has reached target, coming from start
+func targetReached(start, value, target float64) bool {
+	if target >= start {
+		return value >= target
+	}
+	return value <= target
+}
+
+// actualEntries returns the entries that are not projected
+func actualEntries(entries []models.Entry) []models.Entry {
+	actual := make([]models.Entry, 0, len(entries))
+	for _, e := range entries {
+		if !e.Projected {
+			actual = append(actual, e)
+		}
+	}
+	return actual
+}
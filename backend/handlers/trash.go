@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"backend/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Types of the items in the trash, as used in restore paths
+const (
+	trashDatasets = "datasets"
+	trashEntries  = "entries"
+
+	datasetNotInTrash = "dataset not found in trash"
+	entryNotInTrash   = "entry not found in trash"
+)
+
+// ListTrashHandler lists the deleted datasets the user owns and the deleted entries of the datasets they can access
+func (h *Handler) ListTrashHandler(w http.ResponseWriter, r *http.Request) {
+	trash, err := h.Store.ListTrash(currentUser(r).Id)
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	trash.RetentionDays = int(h.TrashRetention.Hours() / 24)
+	writeJSON(w, trash)
+}
+
+// RestoreTrashHandler takes a dataset or an entry out of the trash and returns it
+func (h *Handler) RestoreTrashHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, err := parseID(r, id, "invalid id")
+	if err != nil {
+		handleError(w, err, "")
+		return
+	}
+	switch mux.Vars(r)["type"] {
+	case trashDatasets:
+		h.restoreDataset(w, r, itemID)
+	case trashEntries:
+		h.restoreEntry(w, r, itemID)
+	default:
+		handleError(w, &httpError{http.StatusNotFound, "unknown trash type, expected datasets or entries"}, "")
+	}
+}
+
+// restoreDataset restores a dataset of the trash, which only its owner may do
+func (h *Handler) restoreDataset(w http.ResponseWriter, r *http.Request, id int) {
+	d, err := h.Store.GetTrashedDataset(id)
+	if err != nil {
+		handleError(w, err, datasetNotInTrash)
+		return
+	}
+	if err := h.authorize(r, d, models.RoleOwner); err != nil {
+		handleError(w, err, datasetNotInTrash)
+		return
+	}
+	if err := h.auditedStore(r).RestoreDataset(id); err != nil {
+		handleError(w, err, datasetNotInTrash)
+		return
+	}
+	restored, err := h.Store.GetDataset(id)
+	if err != nil {
+		handleError(w, err, datasetNotFound)
+		return
+	}
+	w.Header().Set(etagHeader, etag(restored.Version))
+	writeJSON(w, restored)
+}
+
+// restoreEntry restores an entry of the trash, if its dataset is not in the trash itself
+func (h *Handler) restoreEntry(w http.ResponseWriter, r *http.Request, id int) {
+	e, err := h.Store.GetTrashedEntry(id)
+	if err != nil {
+		handleError(w, err, entryNotInTrash)
+		return
+	}
+	if _, err := h.authorizedDataset(r, e.DatasetId, models.RoleEditor); err != nil {
+		handleError(w, err, entryNotInTrash)
+		return
+	}
+	if err := h.auditedStore(r).RestoreEntry(id); err != nil {
+		handleError(w, err, entryNotInTrash)
+		return
+	}
+	restored, err := h.Store.GetEntry(id)
+	if err != nil {
+		handleError(w, err, entryNotFound)
+		return
+	}
+	w.Header().Set(etagHeader, etag(restored.Version))
+	writeJSON(w, restored)
+}
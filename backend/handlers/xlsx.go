@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"archive/zip"
+	"backend/models"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	xlsxMainNamespace = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	xlsxRelNamespace  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+	// maxSheetNameLength is the longest sheet name spreadsheet applications accept
+	maxSheetNameLength = 31
+
+	// Cell style indices into the cellXfs of xlsxStyles
+	xlsxStyleDate = 1
+	xlsxStyleBold = 2
+)
+
+// xlsxEpoch is the day spreadsheet date serial numbers count from
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxSheetNameReplacer removes the characters that are not allowed in sheet names
+var xlsxSheetNameReplacer = strings.NewReplacer("[", "", "]", "", ":", "", "*", "", "?", "", "/", "", `\`, "")
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="` + xlsxMainNamespace + `">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+</cellXfs>
+<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>
+</styleSheet>`
+
+// xlsxExportWriter writes an Office Open XML workbook with one worksheet per dataset.
+// Each worksheet lists the dataset metadata above a table of its entries.
+type xlsxExportWriter struct {
+	zw         *zip.Writer
+	sheetNames []string
+}
+
+func newXLSXExportWriter(w io.Writer) (exportWriter, error) {
+	return &xlsxExportWriter{zw: zip.NewWriter(w)}, nil
+}
+
+func (x *xlsxExportWriter) WriteDataset(d models.Dataset, entries []models.Entry) error {
+	x.sheetNames = append(x.sheetNames, x.uniqueSheetName(d))
+
+	sheet := &xlsxSheet{}
+	sheet.row(xlsxText("Name", xlsxStyleBold), xlsxText(d.Name, 0))
+	sheet.row(xlsxText("Description", xlsxStyleBold), xlsxText(d.Description, 0))
+	sheet.row(xlsxText("Symbol", xlsxStyleBold), xlsxText(d.Symbol, 0))
+	sheet.row(xlsxText("Kind", xlsxStyleBold), xlsxText(d.Kind, 0))
+	sheet.row(xlsxText("Target value", xlsxStyleBold), xlsxOptionalNumber(d.TargetValue))
+	sheet.row(xlsxText("Start date", xlsxStyleBold), xlsxOptionalDate(d.StartDate))
+	sheet.row(xlsxText("End date", xlsxStyleBold), xlsxOptionalDate(d.EndDate))
+	sheet.row()
+	sheet.row(
+		xlsxText("Date", xlsxStyleBold),
+		xlsxText("Value", xlsxStyleBold),
+		xlsxText("Label", xlsxStyleBold),
+		xlsxText("Projected", xlsxStyleBold),
+		xlsxText("Lower", xlsxStyleBold),
+		xlsxText("Upper", xlsxStyleBold),
+	)
+	for _, e := range entries {
+		sheet.row(
+			xlsxDate(e.Date),
+			xlsxNumber(e.Value),
+			xlsxText(e.Label, 0),
+			xlsxBool(e.Projected),
+			xlsxOptionalNumber(e.Lower),
+			xlsxOptionalNumber(e.Upper),
+		)
+	}
+
+	return x.writeFile(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(x.sheetNames)), sheet.xml())
+}
+
+func (x *xlsxExportWriter) Close() error {
+	var workbook, workbookRels, contentTypes strings.Builder
+
+	workbook.WriteString(xml.Header + `<workbook xmlns="` + xlsxMainNamespace + `" xmlns:r="` + xlsxRelNamespace + `"><sheets>`)
+	workbookRels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	contentTypes.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+
+	// A workbook needs at least one sheet to open
+	if len(x.sheetNames) == 0 {
+		x.sheetNames = append(x.sheetNames, "Datasets")
+		if err := x.writeFile("xl/worksheets/sheet1.xml", (&xlsxSheet{}).xml()); err != nil {
+			return err
+		}
+	}
+
+	for i, name := range x.sheetNames {
+		n := i + 1
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(name), n, n)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="%s/worksheet" Target="worksheets/sheet%d.xml"/>`,
+			n, xlsxRelNamespace, n)
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+	}
+	fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="%s/styles" Target="styles.xml"/>`,
+		len(x.sheetNames)+1, xlsxRelNamespace)
+
+	workbook.WriteString(`</sheets></workbook>`)
+	workbookRels.WriteString(`</Relationships>`)
+	contentTypes.WriteString(`</Types>`)
+
+	files := []struct{ name, content string }{
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.String()},
+		{"xl/styles.xml", xlsxStyles},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="` + xlsxRelNamespace + `/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"[Content_Types].xml", contentTypes.String()},
+	}
+	for _, f := range files {
+		if err := x.writeFile(f.name, f.content); err != nil {
+			return err
+		}
+	}
+	return x.zw.Close()
+}
+
+// uniqueSheetName derives a valid sheet name from the dataset that no earlier sheet uses yet
+func (x *xlsxExportWriter) uniqueSheetName(d models.Dataset) string {
+	base := strings.TrimSpace(xlsxSheetNameReplacer.Replace(d.Name))
+	if base == "" {
+		base = "Dataset " + strconv.Itoa(d.Id)
+	}
+
+	name := truncateRunes(base, maxSheetNameLength)
+	for n := 2; x.hasSheet(name); n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		name = truncateRunes(base, maxSheetNameLength-len(suffix)) + suffix
+	}
+	return name
+}
+
+// hasSheet reports whether a sheet with name exists, ignoring case like spreadsheet applications do
+func (x *xlsxExportWriter) hasSheet(name string) bool {
+	for _, existing := range x.sheetNames {
+		if strings.EqualFold(existing, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *xlsxExportWriter) writeFile(name, content string) error {
+	f, err := x.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, content)
+	return err
+}
+
+// xlsxSheet builds the XML of a worksheet row by row
+type xlsxSheet struct {
+	rows strings.Builder
+	n    int
+}
+
+// xlsxCell renders a cell given its reference, such as B3
+type xlsxCell func(ref string) string
+
+func (s *xlsxSheet) row(cells ...xlsxCell) {
+	s.n++
+	fmt.Fprintf(&s.rows, `<row r="%d">`, s.n)
+	for i, cell := range cells {
+		s.rows.WriteString(cell(xlsxColumnName(i) + strconv.Itoa(s.n)))
+	}
+	s.rows.WriteString(`</row>`)
+}
+
+func (s *xlsxSheet) xml() string {
+	return xml.Header + `<worksheet xmlns="` + xlsxMainNamespace + `"><sheetData>` + s.rows.String() + `</sheetData></worksheet>`
+}
+
+func xlsxText(text string, style int) xlsxCell {
+	return func(ref string) string {
+		return fmt.Sprintf(`<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlEscape(text))
+	}
+}
+
+func xlsxNumber(v float64) xlsxCell {
+	return func(ref string) string {
+		return fmt.Sprintf(`<c r="%s"><v>%s</v></c>`, ref, formatFloat(v))
+	}
+}
+
+func xlsxBool(b bool) xlsxCell {
+	value := 0
+	if b {
+		value = 1
+	}
+	return func(ref string) string {
+		return fmt.Sprintf(`<c r="%s" t="b"><v>%d</v></c>`, ref, value)
+	}
+}
+
+// xlsxDate stores the wall-clock time of date as a spreadsheet date serial number
+func xlsxDate(date time.Time) xlsxCell {
+	wallClock := time.Date(date.Year(), date.Month(), date.Day(), date.Hour(), date.Minute(), date.Second(), 0, time.UTC)
+	serial := wallClock.Sub(xlsxEpoch).Hours() / 24
+	return func(ref string) string {
+		return fmt.Sprintf(`<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleDate, formatFloat(serial))
+	}
+}
+
+func xlsxOptionalNumber(v *float64) xlsxCell {
+	if v == nil {
+		return xlsxEmpty
+	}
+	return xlsxNumber(*v)
+}
+
+func xlsxOptionalDate(date *time.Time) xlsxCell {
+	if date == nil {
+		return xlsxEmpty
+	}
+	return xlsxDate(*date)
+}
+
+func xlsxEmpty(string) string { return "" }
+
+// xlsxColumnName converts a zero-based column index into its letters, such as A, Z or AA
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// xmlEscape escapes text for XML, replacing characters XML cannot represent
+func xmlEscape(text string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+// truncateRunes shortens s to at most n characters
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
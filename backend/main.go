@@ -1,90 +1,298 @@
 package main
 
 import (
+	"backend/database"
 	"backend/handlers"
 	"backend/migrations"
+	"backend/models"
+	"backend/notify"
 	"backend/utils"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
-	db, err := dbSetup()
+	// The migrate command manages the schema version itself, every other run migrates to the latest version
+	store, err := storeSetup(len(os.Args) < 2 || os.Args[1] != "migrate")
 	if err != nil {
 		utils.Error(err.Error())
 		return
 	}
-	if err := httpSetup(db); err != nil {
+	if len(os.Args) > 1 {
+		if err := runCommand(store, os.Args[1:]); err != nil {
+			utils.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if err := httpSetup(store); err != nil {
 		utils.Error(err.Error())
 		return
 	}
 }
 
-func dbSetup() (*sql.DB, error) {
-	db, err := utils.ConnectDB()
+// storeSetup opens the storage backend selected by STORAGE_BACKEND, Postgres by default.
+// SQLite stores its data in the file at SQLITE_PATH, the memory backend loses it on exit.
+func storeSetup(migrate bool) (database.Store, error) {
+	backend := os.Getenv("STORAGE_BACKEND")
+	if backend == "" {
+		backend = database.BackendPostgres
+	}
+
+	var db *sql.DB
+	var err error
+	switch backend {
+	case database.BackendMemory:
+		utils.Warning("Using in-memory storage, all data is lost when the server stops.")
+		return database.NewMemoryStore(), nil
+	case database.BackendPostgres:
+		db, err = utils.ConnectDB()
+	case database.BackendSQLite:
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = defaultSQLitePath
+		}
+		db, err = utils.ConnectSQLite(path)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q, expected postgres, sqlite or memory", backend)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	//if mErr := migrations.Down(db); mErr != nil {
-	//	return nil, mErr
-	//}
-	if mErr := migrations.Up(db); mErr != nil {
-		return nil, mErr
+	if migrate {
+		if mErr := migrations.Up(db, backend); mErr != nil {
+			return nil, mErr
+		}
 	}
-	return db, nil
+	return database.NewSQLStore(db, backend)
 }
 
 const (
 	port = ":8080"
 
+	defaultSQLitePath = "dataTracker.db"
+
+	defaultTrashRetentionDays = 30
+	// trashPurgeInterval is how often datasets and entries past the retention period are purged from the trash
+	trashPurgeInterval = time.Hour
+	// alertCheckInterval is how often all alert rules are evaluated, besides whenever entries change
+	alertCheckInterval = time.Hour
+
+	defaultSMTPPort = "587"
+
 	// Route parts
-	routeDatasets  = "/datasets"
-	routeEntries   = "/entries"
-	routeID        = "/{id}"
-	routeDatasetID = "/{datasetId}"
-	projected      = "/projected"
+	routeDatasets   = "/datasets"
+	routeEntries    = "/entries"
+	routeID         = "/{id}"
+	routeDatasetID  = "/{datasetId}"
+	projected       = "/projected"
+	routeProjectors = "/projectors"
+	routeForecast   = "/forecast"
+	routeExport     = "/export"
+	routeBackup     = "/backup"
+	routeRestore    = "/restore"
+	routeAuth       = "/auth"
+	routeMembers    = "/members"
+	routeKeys       = "/keys"
+	routeHistory    = "/history"
+	routeTrash      = "/trash"
+	routeAlertRules = "/alert-rules"
+	routeAlerts     = "/alerts"
 )
 
-func httpSetup(db *sql.DB) error {
+func httpSetup(store database.Store) error {
 	utils.Info("Setting up HTTP server...")
 
+	retention, err := trashRetention()
+	if err != nil {
+		return err
+	}
+	go purgeTrash(store, retention)
+
+	notifiers, err := alertNotifiers()
+	if err != nil {
+		return err
+	}
+	alerter := handlers.NewAlerter(store, notifiers...)
+	go checkAlerts(alerter)
+
 	r := mux.NewRouter()
-	h := &handlers.Handler{DB: db}
+	h := &handlers.Handler{Store: store, TrashRetention: retention, Alerter: alerter}
+
+	// Registration and login are the only routes accessible without a token
+	authRouter := r.PathPrefix(routeAuth).Subrouter()
+	authRouter.HandleFunc("/register", h.RegisterHandler).Methods(http.MethodPost)
+	authRouter.HandleFunc("/login", h.LoginHandler).Methods(http.MethodPost)
+
+	// Creating and listing entries also accepts the API keys of the dataset, for scripts and meters
+	ingestRouter := r.PathPrefix(routeDatasets + routeDatasetID + routeEntries).Subrouter()
+	ingestRouter.Use(h.AuthenticateWithAPIKey)
+	ingestRouter.HandleFunc("", h.CreateEntryHandler).Methods(http.MethodPost)
+	ingestRouter.HandleFunc("", h.ListEntriesHandler).Methods(http.MethodGet)
+
+	api := r.PathPrefix("/").Subrouter()
+	api.Use(h.Authenticate)
+	api.HandleFunc(routeAuth+"/logout", h.LogoutHandler).Methods(http.MethodPost)
+	api.HandleFunc(routeAuth+"/me", h.MeHandler).Methods(http.MethodGet)
 
 	// Dataset routes
-	datasetRouter := r.PathPrefix(routeDatasets).Subrouter()
+	datasetRouter := api.PathPrefix(routeDatasets).Subrouter()
 	datasetRouter.HandleFunc("", h.CreateDatasetHandler).Methods(http.MethodPost)
 	datasetRouter.HandleFunc("", h.ListDatasetsHandler).Methods(http.MethodGet)
 	datasetRouter.HandleFunc(routeID, h.GetDatasetHandler).Methods(http.MethodGet)
 	datasetRouter.HandleFunc(routeID, h.UpdateDatasetHandler).Methods(http.MethodPut)
+	datasetRouter.HandleFunc(routeID, h.PatchDatasetHandler).Methods(http.MethodPatch)
 	datasetRouter.HandleFunc(routeID, h.DeleteDatasetHandler).Methods(http.MethodDelete)
+	datasetRouter.HandleFunc(routeID+routeForecast+"/summary", h.ForecastSummaryHandler).Methods(http.MethodGet)
+	datasetRouter.HandleFunc(routeID+routeExport, h.ExportDatasetHandler).Methods(http.MethodGet)
+	datasetRouter.HandleFunc(routeID+routeHistory, h.DatasetHistoryHandler).Methods(http.MethodGet)
+
+	// Members a dataset is shared with
+	datasetRouter.HandleFunc(routeID+routeMembers, h.ListMembersHandler).Methods(http.MethodGet)
+	datasetRouter.HandleFunc(routeID+routeMembers, h.AddMemberHandler).Methods(http.MethodPost)
+	datasetRouter.HandleFunc(routeID+routeMembers+"/{userId}", h.RemoveMemberHandler).Methods(http.MethodDelete)
+
+	// API keys of a dataset
+	datasetRouter.HandleFunc(routeID+routeKeys, h.ListAPIKeysHandler).Methods(http.MethodGet)
+	datasetRouter.HandleFunc(routeID+routeKeys, h.CreateAPIKeyHandler).Methods(http.MethodPost)
+	datasetRouter.HandleFunc(routeID+routeKeys+"/{keyId}", h.RevokeAPIKeyHandler).Methods(http.MethodDelete)
+
+	// Alert rules of a dataset and the alerts they raised
+	datasetRouter.HandleFunc(routeID+routeAlertRules, h.ListAlertRulesHandler).Methods(http.MethodGet)
+	datasetRouter.HandleFunc(routeID+routeAlertRules, h.CreateAlertRuleHandler).Methods(http.MethodPost)
+	datasetRouter.HandleFunc(routeID+routeAlertRules+"/{ruleId}", h.DeleteAlertRuleHandler).Methods(http.MethodDelete)
+	datasetRouter.HandleFunc(routeID+routeAlerts, h.ListAlertsHandler).Methods(http.MethodGet)
 
 	// Entries under dataset
 	entryRouter := datasetRouter.PathPrefix(routeDatasetID + routeEntries).Subrouter()
-	entryRouter.HandleFunc("", h.CreateEntryHandler).Methods(http.MethodPost)
-	entryRouter.HandleFunc("", h.ListEntriesHandler).Methods(http.MethodGet)
+	entryRouter.HandleFunc("/batch", h.BatchEntriesHandler).Methods(http.MethodPost)
+	entryRouter.HandleFunc("/import", h.ImportEntriesHandler).Methods(http.MethodPost)
+	entryRouter.HandleFunc("/aggregate", h.AggregateEntriesHandler).Methods(http.MethodGet)
+	entryRouter.HandleFunc(projected, h.ProjectedHandler).Methods(http.MethodGet)
 	entryRouter.HandleFunc(projected+"/target", h.ProjectedUntilTargetHandler).Methods(http.MethodGet)
 	entryRouter.HandleFunc(projected+"/endDate", h.ProjectedUntilEndDateHandler).Methods(http.MethodGet)
 
+	// Export of all datasets
+	api.HandleFunc(routeExport, h.ExportAllHandler).Methods(http.MethodGet)
+
+	// Backup and restore of all datasets
+	api.HandleFunc(routeBackup, h.BackupHandler).Methods(http.MethodGet)
+	api.HandleFunc(routeRestore, h.RestoreHandler).Methods(http.MethodPost)
+
+	// Projection strategies
+	api.HandleFunc(routeProjectors, h.ListProjectorsHandler).Methods(http.MethodGet)
+
 	// Entries by ID
-	r.HandleFunc(routeEntries+routeID, h.UpdateEntryHandler).Methods(http.MethodPut)
-	r.HandleFunc(routeEntries+routeID, h.DeleteEntryHandler).Methods(http.MethodDelete)
+	api.HandleFunc(routeEntries+routeID, h.GetEntryHandler).Methods(http.MethodGet)
+	api.HandleFunc(routeEntries+routeID, h.UpdateEntryHandler).Methods(http.MethodPut)
+	api.HandleFunc(routeEntries+routeID, h.PatchEntryHandler).Methods(http.MethodPatch)
+	api.HandleFunc(routeEntries+routeID, h.DeleteEntryHandler).Methods(http.MethodDelete)
+	api.HandleFunc(routeEntries+routeID+routeHistory, h.EntryHistoryHandler).Methods(http.MethodGet)
+
+	// Deleted datasets and entries
+	api.HandleFunc(routeTrash, h.ListTrashHandler).Methods(http.MethodGet)
+	api.HandleFunc(routeTrash+"/{type}"+routeID+"/restore", h.RestoreTrashHandler).Methods(http.MethodPost)
 
 	utils.Success(fmt.Sprintf("Server starting on port %s", port))
-	return http.ListenAndServe(port, enableCors(r))
+	return http.ListenAndServe(port, enableCors(allowedOrigins(), r))
 }
 
-// enableCors enables CORS for all routes
-func enableCors(next http.Handler) http.Handler {
+// trashRetention returns how long deleted datasets and entries are kept in the trash,
+// TRASH_RETENTION_DAYS or 30 days by default
+func trashRetention() (time.Duration, error) {
+	days := defaultTrashRetentionDays
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days < 1 {
+			return 0, fmt.Errorf("invalid TRASH_RETENTION_DAYS %q, expected a positive number of days", value)
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// purgeTrash permanently deletes what was moved into the trash longer than retention ago, now and then every hour.
+// The purged datasets and entries are recorded in the audit log.
+func purgeTrash(store database.Store, retention time.Duration) {
+	store = database.NewAuditedStore(store, models.Actor{Name: "trash purge"})
+	for {
+		purged, err := store.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			utils.Error("Failed to purge trash: " + err.Error())
+		} else if purged > 0 {
+			utils.Info(fmt.Sprintf("Purged %d datasets and entries from the trash", purged))
+		}
+		time.Sleep(trashPurgeInterval)
+	}
+}
+
+// alertNotifiers returns the notifiers alerts can be delivered with. Alerts are always logged and posted to webhooks,
+// emails are sent through the server at SMTP_HOST and SMTP_PORT if it is set, from SMTP_FROM and
+// authenticating with SMTP_USERNAME and SMTP_PASSWORD if a username is set.
+func alertNotifiers() ([]notify.Notifier, error) {
+	notifiers := []notify.Notifier{notify.Log{}, notify.NewWebhook()}
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return notifiers, nil
+	}
+	config := notify.SMTPConfig{
+		Host:     host,
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if config.Port == "" {
+		config.Port = defaultSMTPPort
+	}
+	if config.From == "" {
+		return nil, errors.New("SMTP_FROM is required to send emails")
+	}
+	return append(notifiers, notify.NewEmail(config)), nil
+}
+
+// checkAlerts evaluates the alert rules of all datasets, now and then every hour,
+// so rules about time passing apply without any change to the entries
+func checkAlerts(alerter *handlers.Alerter) {
+	for {
+		if err := alerter.Evaluate(0); err != nil {
+			utils.Error("Failed to evaluate alert rules: " + err.Error())
+		}
+		time.Sleep(alertCheckInterval)
+	}
+}
+
+// allowedOrigins returns the origins listed in the comma separated CORS_ORIGINS environment variable.
+// Without it, only the frontend served from the same origin can call the API.
+func allowedOrigins() map[string]bool {
+	origins := map[string]bool{}
+	for _, origin := range strings.Split(os.Getenv("CORS_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins[origin] = true
+		}
+	}
+	return origins
+}
+
+// enableCors enables CORS for all routes, for requests from the allowed origins
+func enableCors(origins map[string]bool, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h := w.Header()
-		h.Set("Access-Control-Allow-Origin", "*")
-		h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		h.Set("Access-Control-Allow-Headers", "Content-Type")
+		h.Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); origins[origin] {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		h.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match")
+		h.Set("Access-Control-Expose-Headers", "X-Total-Count, Content-Disposition, ETag")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
 			return
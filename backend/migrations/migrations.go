@@ -1,128 +1,220 @@
 package migrations
 
 import (
+	"backend/database"
 	"backend/utils"
+	"context"
 	"database/sql"
-	"errors"
+	"fmt"
 )
 
-// Up runs all migrations
-func Up(db *sql.DB) error {
+// advisoryLockKey identifies the Postgres advisory lock held while migrating,
+// so several backend instances starting at once do not migrate concurrently
+const advisoryLockKey = 7_302_114_105
+
+// Migration is a numbered schema change with the statements to apply and to revert it in each SQL backend
+type Migration struct {
+	Version  int
+	Name     string
+	Postgres Statements
+	SQLite   Statements
+}
+
+// Statements apply (Up) or revert (Down) a migration
+type Statements struct {
+	Up   []string
+	Down []string
+}
+
+// statements returns the statements of the migration for backend
+func (m Migration) statements(backend string) Statements {
+	if backend == database.BackendSQLite {
+		return m.SQLite
+	}
+	return m.Postgres
+}
+
+// Up migrates the schema to the latest version
+func Up(db *sql.DB, backend string) error {
 	utils.Info("Running migrations, if necessary...")
-	return runMigration(db, true)
+	return MigrateTo(db, backend, Latest())
 }
 
 // Down rolls back all migrations
-func Down(db *sql.DB) error {
+func Down(db *sql.DB, backend string) error {
 	utils.Info("Rolling back migrations, if necessary...")
-	return runMigration(db, false)
+	return MigrateTo(db, backend, 0)
 }
 
-// runMigration handles both Up and Down migrations
-func runMigration(db *sql.DB, create bool) error {
-	migrationsApplied, err := areTablesPresent(db)
+// Latest returns the version of the newest migration
+func Latest() int {
+	return all[len(all)-1].Version
+}
+
+// Version returns the version the schema is currently migrated to, 0 if no migration is applied
+func Version(db *sql.DB, backend string) (int, error) {
+	conn, err := db.Conn(context.Background())
 	if err != nil {
-		utils.Error(err.Error())
-		return err
+		return 0, err
 	}
+	defer closeConn(conn)
 
-	// Skip if already in the desired state
-	if (create && migrationsApplied) || (!create && !migrationsApplied) {
-		status := map[bool]string{true: "applied", false: "rolled back"}[create]
-		utils.Info("Migrations already " + status + ". Skipping...")
-		return nil
+	if err := ensureVersionTable(conn, backend); err != nil {
+		return 0, err
 	}
+	return currentVersion(conn)
+}
 
-	var (
-		queries []string
-		msg     string
-	)
+// MigrateTo applies or reverts migrations until the schema is at the given version.
+// Each migration runs in its own transaction together with the update of schema_migrations.
+func MigrateTo(db *sql.DB, backend string, target int) error {
+	if target < 0 || target > Latest() {
+		return fmt.Errorf("invalid migration version %d, expected 0 to %d", target, Latest())
+	}
 
-	if create {
-		queries = CreateDatasetsAndEntries
-		msg = "Migrations applied successfully."
-	} else {
-		queries = DropDatasetsAndEntries
-		msg = "Migrations rolled back."
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeConn(conn)
+
+	// SQLite serializes writing transactions itself and has no advisory locks
+	if backend == database.BackendPostgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+			return err
+		}
+		defer func() {
+			if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, advisoryLockKey); err != nil {
+				utils.Error("failed to release migration lock: " + err.Error())
+			}
+		}()
 	}
 
-	if err := executeAction(db, queries); err != nil {
-		utils.Error("Migration queries failed: " + err.Error())
+	if err := ensureVersionTable(conn, backend); err != nil {
 		return err
 	}
+	current, err := currentVersion(conn)
+	if err != nil {
+		return err
+	}
+	if current == target {
+		utils.Info(fmt.Sprintf("Schema is at version %d. Skipping...", current))
+		return nil
+	}
 
-	utils.Success(msg)
+	if target > current {
+		for _, m := range all {
+			if m.Version > current && m.Version <= target {
+				if err := apply(conn, m.Version, m.Name, m.statements(backend).Up, true); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		for i := len(all) - 1; i >= 0; i-- {
+			if m := all[i]; m.Version <= current && m.Version > target {
+				if err := apply(conn, m.Version, m.Name, m.statements(backend).Down, false); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	utils.Success(fmt.Sprintf("Schema migrated from version %d to %d.", current, target))
 	return nil
 }
 
-// areTablesPresent checks if the datasets and entries tables exist
-func areTablesPresent(db *sql.DB) (bool, error) {
-	var tableName string
-
-	err := db.QueryRow(`
-		SELECT table_name 
-		FROM information_schema.tables 
-		WHERE table_schema = 'public' AND table_name = 'datasets'
-	`).Scan(&tableName)
+// Rollback reverts the given number of the most recently applied migrations
+func Rollback(db *sql.DB, backend string, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("invalid number of steps %d, expected at least 1", steps)
+	}
+	current, err := Version(db, backend)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, nil
+		return err
+	}
+
+	target := 0
+	applied := 0
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Version > current {
+			continue
+		}
+		if applied == steps {
+			target = all[i].Version
+			break
 		}
-		return false, err
+		applied++
+	}
+	return MigrateTo(db, backend, target)
+}
+
+// apply runs the up or down statements of a migration and records the result in schema_migrations
+func apply(conn *sql.Conn, version int, name string, queries []string, up bool) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
 
-	err = db.QueryRow(`
-		SELECT table_name 
-		FROM information_schema.tables 
-		WHERE table_schema = 'public' AND table_name = 'entries'
-	`).Scan(&tableName)
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+	for _, query := range queries {
+		if _, err = tx.ExecContext(ctx, query); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		if up {
+			_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, version, name)
+		} else {
+			_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, version)
+		}
+	}
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, nil
+		if rbErr := tx.Rollback(); rbErr != nil {
+			utils.Error("failed to roll back migration: " + rbErr.Error())
 		}
-		return false, err
+		utils.Error(fmt.Sprintf("Migration %d %s (%s) failed: %s", version, name, direction, err.Error()))
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 
-	return true, nil
+	utils.Info(fmt.Sprintf("Migration %d %s applied (%s).", version, name, direction))
+	return nil
 }
 
-func executeAction(db *sql.DB, actions []string) error {
-	for _, query := range actions {
-		if _, err := db.Exec(query); err != nil {
-			return err
-		}
+// ensureVersionTable creates the table tracking the applied migrations
+func ensureVersionTable(conn *sql.Conn, backend string) error {
+	appliedAt := "TIMESTAMPTZ NOT NULL DEFAULT NOW()"
+	if backend == database.BackendSQLite {
+		appliedAt = "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP"
 	}
-	return nil
+	_, err := conn.ExecContext(context.Background(), `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		    version INT PRIMARY KEY,
+		    name TEXT NOT NULL,
+		    applied_at `+appliedAt+`
+		);
+	`)
+	return err
 }
 
-var CreateDatasetsAndEntries = []string{
-	`
-	CREATE TABLE IF NOT EXISTS datasets (
-	    id SERIAL PRIMARY KEY,
-	    name TEXT NOT NULL,
-	    description TEXT,
-	    symbol TEXT,
-	    target_value NUMERIC(15,2),
-	    start_date DATE,
-	    end_date DATE
-	);
-	`,
-	`
-	CREATE TABLE IF NOT EXISTS entries (
-	    id SERIAL PRIMARY KEY,
-	    dataset_id INT NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
-	    value NUMERIC(15,2) NOT NULL,
-	    label TEXT,
-	    date TIMESTAMP NOT NULL DEFAULT NOW()
-	);
-	`,
-	`
-	CREATE INDEX IF NOT EXISTS idx_entries_dataset_id
-	ON entries(dataset_id);
-	`,
+// currentVersion returns the highest applied migration version
+func currentVersion(conn *sql.Conn) (int, error) {
+	var version int
+	err := conn.QueryRowContext(context.Background(),
+		`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
 }
 
-var DropDatasetsAndEntries = []string{
-	`DROP TABLE IF EXISTS entries;`,
-	`DROP TABLE IF EXISTS datasets;`,
+func closeConn(conn *sql.Conn) {
+	if err := conn.Close(); err != nil {
+		utils.Error(err.Error())
+	}
 }
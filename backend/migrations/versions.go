@@ -0,0 +1,448 @@
+package migrations
+
+// all lists every migration in ascending version order. Never change a migration that was released,
+// add a new one instead. The first migrations only create what is missing, so installations set up
+// before schema_migrations existed are adopted without losing data.
+var all = []Migration{
+	{
+		Version: 1,
+		Name:    "create_datasets_and_entries",
+		Postgres: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS datasets (
+				    id SERIAL PRIMARY KEY,
+				    name TEXT NOT NULL,
+				    description TEXT,
+				    symbol TEXT,
+				    target_value NUMERIC(15,2),
+				    start_date DATE,
+				    end_date DATE
+				);
+				`,
+				`
+				CREATE TABLE IF NOT EXISTS entries (
+				    id SERIAL PRIMARY KEY,
+				    dataset_id INT NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+				    value NUMERIC(15,2) NOT NULL,
+				    label TEXT,
+				    date TIMESTAMP NOT NULL DEFAULT NOW()
+				);
+				`,
+				`
+				CREATE INDEX IF NOT EXISTS idx_entries_dataset_id
+				ON entries(dataset_id);
+				`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS entries;`,
+				`DROP TABLE IF EXISTS datasets;`,
+			},
+		},
+		SQLite: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS datasets (
+				    id INTEGER PRIMARY KEY AUTOINCREMENT,
+				    name TEXT NOT NULL,
+				    description TEXT,
+				    symbol TEXT,
+				    target_value NUMERIC(15,2),
+				    start_date DATE,
+				    end_date DATE
+				);
+				`,
+				`
+				CREATE TABLE IF NOT EXISTS entries (
+				    id INTEGER PRIMARY KEY AUTOINCREMENT,
+				    dataset_id INTEGER NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+				    value NUMERIC(15,2) NOT NULL,
+				    label TEXT,
+				    date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+				);
+				`,
+				`
+				CREATE INDEX IF NOT EXISTS idx_entries_dataset_id
+				ON entries(dataset_id);
+				`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS entries;`,
+				`DROP TABLE IF EXISTS datasets;`,
+			},
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add_dataset_kind",
+		Postgres: Statements{
+			Up: []string{
+				`
+				ALTER TABLE datasets
+				ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'cumulative';
+				`,
+			},
+			Down: []string{
+				`ALTER TABLE datasets DROP COLUMN IF EXISTS kind;`,
+			},
+		},
+		SQLite: Statements{
+			Up: []string{
+				`ALTER TABLE datasets ADD COLUMN kind TEXT NOT NULL DEFAULT 'cumulative';`,
+			},
+			Down: []string{
+				`ALTER TABLE datasets DROP COLUMN kind;`,
+			},
+		},
+	},
+	{
+		Version: 3,
+		Name:    "add_versions",
+		Postgres: Statements{
+			Up: []string{
+				`ALTER TABLE datasets ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`,
+				`ALTER TABLE entries ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`,
+			},
+			Down: []string{
+				`ALTER TABLE entries DROP COLUMN IF EXISTS version;`,
+				`ALTER TABLE datasets DROP COLUMN IF EXISTS version;`,
+			},
+		},
+		SQLite: Statements{
+			Up: []string{
+				`ALTER TABLE datasets ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+				`ALTER TABLE entries ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+			},
+			Down: []string{
+				`ALTER TABLE entries DROP COLUMN version;`,
+				`ALTER TABLE datasets DROP COLUMN version;`,
+			},
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_users_and_sessions",
+		Postgres: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS users (
+				    id SERIAL PRIMARY KEY,
+				    username TEXT NOT NULL UNIQUE,
+				    password_hash TEXT NOT NULL,
+				    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+				);
+				`,
+				`
+				CREATE TABLE IF NOT EXISTS sessions (
+				    token_hash TEXT PRIMARY KEY,
+				    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				    expires_at TIMESTAMP NOT NULL
+				);
+				`,
+				`ALTER TABLE datasets ADD COLUMN IF NOT EXISTS owner_id INT REFERENCES users(id) ON DELETE CASCADE;`,
+				`CREATE INDEX IF NOT EXISTS idx_datasets_owner_id ON datasets(owner_id);`,
+			},
+			Down: []string{
+				`DROP INDEX IF EXISTS idx_datasets_owner_id;`,
+				`ALTER TABLE datasets DROP COLUMN IF EXISTS owner_id;`,
+				`DROP TABLE IF EXISTS sessions;`,
+				`DROP TABLE IF EXISTS users;`,
+			},
+		},
+		SQLite: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS users (
+				    id INTEGER PRIMARY KEY AUTOINCREMENT,
+				    username TEXT NOT NULL UNIQUE,
+				    password_hash TEXT NOT NULL,
+				    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+				);
+				`,
+				`
+				CREATE TABLE IF NOT EXISTS sessions (
+				    token_hash TEXT PRIMARY KEY,
+				    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				    expires_at TIMESTAMP NOT NULL
+				);
+				`,
+				// SQLite cannot drop a column referencing another table, so owner_id has no foreign key
+				`ALTER TABLE datasets ADD COLUMN owner_id INTEGER;`,
+				`CREATE INDEX IF NOT EXISTS idx_datasets_owner_id ON datasets(owner_id);`,
+			},
+			Down: []string{
+				`DROP INDEX IF EXISTS idx_datasets_owner_id;`,
+				`ALTER TABLE datasets DROP COLUMN owner_id;`,
+				`DROP TABLE IF EXISTS sessions;`,
+				`DROP TABLE IF EXISTS users;`,
+			},
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_dataset_members",
+		Postgres: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS dataset_members (
+				    dataset_id INT NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+				    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				    role TEXT NOT NULL,
+				    PRIMARY KEY (dataset_id, user_id)
+				);
+				`,
+				`CREATE INDEX IF NOT EXISTS idx_dataset_members_user_id ON dataset_members(user_id);`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS dataset_members;`,
+			},
+		},
+		SQLite: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS dataset_members (
+				    dataset_id INTEGER NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+				    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				    role TEXT NOT NULL,
+				    PRIMARY KEY (dataset_id, user_id)
+				);
+				`,
+				`CREATE INDEX IF NOT EXISTS idx_dataset_members_user_id ON dataset_members(user_id);`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS dataset_members;`,
+			},
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add_api_keys",
+		Postgres: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS api_keys (
+				    id SERIAL PRIMARY KEY,
+				    dataset_id INT NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+				    name TEXT NOT NULL,
+				    scope TEXT NOT NULL,
+				    prefix TEXT NOT NULL,
+				    key_hash TEXT NOT NULL UNIQUE,
+				    created_by INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				    last_used_at TIMESTAMP,
+				    revoked_at TIMESTAMP
+				);
+				`,
+				`CREATE INDEX IF NOT EXISTS idx_api_keys_dataset_id ON api_keys(dataset_id);`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS api_keys;`,
+			},
+		},
+		SQLite: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS api_keys (
+				    id INTEGER PRIMARY KEY AUTOINCREMENT,
+				    dataset_id INTEGER NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+				    name TEXT NOT NULL,
+				    scope TEXT NOT NULL,
+				    prefix TEXT NOT NULL,
+				    key_hash TEXT NOT NULL UNIQUE,
+				    created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				    last_used_at TIMESTAMP,
+				    revoked_at TIMESTAMP
+				);
+				`,
+				`CREATE INDEX IF NOT EXISTS idx_api_keys_dataset_id ON api_keys(dataset_id);`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS api_keys;`,
+			},
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add_audit_log",
+		Postgres: Statements{
+			Up: []string{
+				// No foreign keys, the log outlives the datasets, entries, users and keys it mentions
+				`
+				CREATE TABLE IF NOT EXISTS audit_log (
+				    id BIGSERIAL PRIMARY KEY,
+				    dataset_id INT NOT NULL,
+				    entry_id INT,
+				    entity TEXT NOT NULL,
+				    action TEXT NOT NULL,
+				    user_id INT,
+				    api_key_id INT,
+				    actor TEXT NOT NULL,
+				    old_value JSONB,
+				    new_value JSONB,
+				    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+				);
+				`,
+				`CREATE INDEX IF NOT EXISTS idx_audit_log_dataset_id ON audit_log(dataset_id, id);`,
+				`CREATE INDEX IF NOT EXISTS idx_audit_log_entry_id ON audit_log(entry_id, id);`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS audit_log;`,
+			},
+		},
+		SQLite: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS audit_log (
+				    id INTEGER PRIMARY KEY AUTOINCREMENT,
+				    dataset_id INTEGER NOT NULL,
+				    entry_id INTEGER,
+				    entity TEXT NOT NULL,
+				    action TEXT NOT NULL,
+				    user_id INTEGER,
+				    api_key_id INTEGER,
+				    actor TEXT NOT NULL,
+				    old_value TEXT,
+				    new_value TEXT,
+				    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+				);
+				`,
+				`CREATE INDEX IF NOT EXISTS idx_audit_log_dataset_id ON audit_log(dataset_id, id);`,
+				`CREATE INDEX IF NOT EXISTS idx_audit_log_entry_id ON audit_log(entry_id, id);`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS audit_log;`,
+			},
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_soft_delete",
+		Postgres: Statements{
+			Up: []string{
+				`ALTER TABLE datasets ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;`,
+				`ALTER TABLE entries ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;`,
+				`CREATE INDEX IF NOT EXISTS idx_datasets_deleted_at ON datasets(deleted_at) WHERE deleted_at IS NOT NULL;`,
+				`CREATE INDEX IF NOT EXISTS idx_entries_deleted_at ON entries(deleted_at) WHERE deleted_at IS NOT NULL;`,
+			},
+			Down: []string{
+				// Rows in the trash were deleted by their users, so they are removed instead of coming back
+				`DELETE FROM entries WHERE deleted_at IS NOT NULL;`,
+				`DELETE FROM datasets WHERE deleted_at IS NOT NULL;`,
+				`DROP INDEX IF EXISTS idx_entries_deleted_at;`,
+				`DROP INDEX IF EXISTS idx_datasets_deleted_at;`,
+				`ALTER TABLE entries DROP COLUMN IF EXISTS deleted_at;`,
+				`ALTER TABLE datasets DROP COLUMN IF EXISTS deleted_at;`,
+			},
+		},
+		SQLite: Statements{
+			Up: []string{
+				`ALTER TABLE datasets ADD COLUMN deleted_at TIMESTAMP;`,
+				`ALTER TABLE entries ADD COLUMN deleted_at TIMESTAMP;`,
+				`CREATE INDEX IF NOT EXISTS idx_datasets_deleted_at ON datasets(deleted_at) WHERE deleted_at IS NOT NULL;`,
+				`CREATE INDEX IF NOT EXISTS idx_entries_deleted_at ON entries(deleted_at) WHERE deleted_at IS NOT NULL;`,
+			},
+			Down: []string{
+				`DELETE FROM entries WHERE deleted_at IS NOT NULL;`,
+				`DELETE FROM datasets WHERE deleted_at IS NOT NULL;`,
+				`DROP INDEX IF EXISTS idx_entries_deleted_at;`,
+				`DROP INDEX IF EXISTS idx_datasets_deleted_at;`,
+				`ALTER TABLE entries DROP COLUMN deleted_at;`,
+				`ALTER TABLE datasets DROP COLUMN deleted_at;`,
+			},
+		},
+	},
+	{
+		Version: 9,
+		Name:    "add_alerts",
+		Postgres: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS alert_rules (
+				    id SERIAL PRIMARY KEY,
+				    dataset_id INT NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+				    name TEXT NOT NULL,
+				    condition TEXT NOT NULL,
+				    threshold NUMERIC(15,2),
+				    direction TEXT NOT NULL DEFAULT '',
+				    days INT NOT NULL DEFAULT 0,
+				    method TEXT NOT NULL DEFAULT '',
+				    notifier TEXT NOT NULL,
+				    target TEXT NOT NULL DEFAULT '',
+				    firing BOOLEAN NOT NULL DEFAULT FALSE,
+				    last_triggered_at TIMESTAMP,
+				    created_by INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+				);
+				`,
+				`CREATE INDEX IF NOT EXISTS idx_alert_rules_dataset_id ON alert_rules(dataset_id);`,
+				// Alerts outlive their rule, so rule_id has no foreign key
+				`
+				CREATE TABLE IF NOT EXISTS alerts (
+				    id SERIAL PRIMARY KEY,
+				    rule_id INT NOT NULL,
+				    dataset_id INT NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+				    rule_name TEXT NOT NULL,
+				    condition TEXT NOT NULL,
+				    message TEXT NOT NULL,
+				    value NUMERIC(15,2),
+				    notifier TEXT NOT NULL,
+				    delivered BOOLEAN NOT NULL,
+				    error TEXT NOT NULL DEFAULT '',
+				    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+				);
+				`,
+				`CREATE INDEX IF NOT EXISTS idx_alerts_dataset_id ON alerts(dataset_id, id);`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS alerts;`,
+				`DROP TABLE IF EXISTS alert_rules;`,
+			},
+		},
+		SQLite: Statements{
+			Up: []string{
+				`
+				CREATE TABLE IF NOT EXISTS alert_rules (
+				    id INTEGER PRIMARY KEY AUTOINCREMENT,
+				    dataset_id INTEGER NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+				    name TEXT NOT NULL,
+				    condition TEXT NOT NULL,
+				    threshold NUMERIC(15,2),
+				    direction TEXT NOT NULL DEFAULT '',
+				    days INTEGER NOT NULL DEFAULT 0,
+				    method TEXT NOT NULL DEFAULT '',
+				    notifier TEXT NOT NULL,
+				    target TEXT NOT NULL DEFAULT '',
+				    firing BOOLEAN NOT NULL DEFAULT 0,
+				    last_triggered_at TIMESTAMP,
+				    created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+				);
+				`,
+				`CREATE INDEX IF NOT EXISTS idx_alert_rules_dataset_id ON alert_rules(dataset_id);`,
+				`
+				CREATE TABLE IF NOT EXISTS alerts (
+				    id INTEGER PRIMARY KEY AUTOINCREMENT,
+				    rule_id INTEGER NOT NULL,
+				    dataset_id INTEGER NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+				    rule_name TEXT NOT NULL,
+				    condition TEXT NOT NULL,
+				    message TEXT NOT NULL,
+				    value NUMERIC(15,2),
+				    notifier TEXT NOT NULL,
+				    delivered BOOLEAN NOT NULL,
+				    error TEXT NOT NULL DEFAULT '',
+				    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+				);
+				`,
+				`CREATE INDEX IF NOT EXISTS idx_alerts_dataset_id ON alerts(dataset_id, id);`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS alerts;`,
+				`DROP TABLE IF EXISTS alert_rules;`,
+			},
+		},
+	},
+}
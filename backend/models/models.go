@@ -1,6 +1,42 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	KindCumulative  = "cumulative"
+	KindIncremental = "incremental"
+
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+
+	AuditDataset = "dataset"
+	AuditEntry   = "entry"
+
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+
+	AlertThreshold      = "threshold"
+	AlertTargetReached  = "target_reached"
+	AlertDeadlineMissed = "deadline_missed"
+	AlertNoEntries      = "no_entries"
+
+	DirectionAbove = "above"
+	DirectionBelow = "below"
+
+	NotifierLog     = "log"
+	NotifierWebhook = "webhook"
+	NotifierEmail   = "email"
+)
 
 type Dataset struct {
 	Id          int        `json:"id"`
@@ -10,13 +46,316 @@ type Dataset struct {
 	TargetValue *float64   `json:"targetValue"`
 	StartDate   *time.Time `json:"startDate,omitempty"`
 	EndDate     *time.Time `json:"endDate,omitempty"`
+	Kind        string     `json:"kind"`
+	Version     int        `json:"version"`
+	OwnerId     int        `json:"ownerId"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"`
 }
 
 type Entry struct {
+	Id        int        `json:"id"`
+	DatasetId int        `json:"datasetId"`
+	Value     float64    `json:"value"`
+	Label     string     `json:"label"`
+	Date      time.Time  `json:"date"`
+	Projected bool       `json:"projected,omitempty"`
+	Lower     *float64   `json:"lower,omitempty"`
+	Upper     *float64   `json:"upper,omitempty"`
+	Version   int        `json:"version,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ErrorResponse struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+type EntryFilter struct {
+	From       *time.Time
+	To         *time.Time
+	Label      string
+	MinValue   *float64
+	MaxValue   *float64
+	Sort       string
+	Desc       bool
+	Limit      int
+	Offset     int
+	Cumulative bool
+}
+
+type Regression struct {
+	Slope     float64 `json:"slope"`
+	Intercept float64 `json:"intercept"`
+	RSquared  float64 `json:"rSquared"`
+}
+
+type Projection struct {
+	Method      string             `json:"method"`
+	Until       string             `json:"until"`
+	Params      map[string]float64 `json:"params,omitempty"`
+	Confidence  float64            `json:"confidence"`
+	StdError    float64            `json:"stdError"`
+	Cadence     string             `json:"cadence,omitempty"`
+	TimeZone    string             `json:"timeZone"`
+	TargetValue *float64           `json:"targetValue,omitempty"`
+	EndDate     *time.Time         `json:"endDate,omitempty"`
+	Regression  *Regression        `json:"regression,omitempty"`
+	Truncated   bool               `json:"truncated"`
+	Entries     []Entry            `json:"entries"`
+}
+
+type ForecastSummary struct {
+	DatasetId          int        `json:"datasetId"`
+	Method             string     `json:"method"`
+	Cadence            string     `json:"cadence,omitempty"`
+	LastValue          *float64   `json:"lastValue"`
+	LastDate           *time.Time `json:"lastDate"`
+	TargetValue        *float64   `json:"targetValue"`
+	EndDate            *time.Time `json:"endDate"`
+	Progress           *float64   `json:"progress"`
+	TargetStatus       string     `json:"targetStatus"`
+	TargetDate         *time.Time `json:"targetDate"`
+	Reason             string     `json:"reason,omitempty"`
+	ValueAtEndDate     *float64   `json:"valueAtEndDate"`
+	RequiredRatePerDay *float64   `json:"requiredRatePerDay"`
+	MaxProjectionSteps int        `json:"maxProjectionSteps"`
+}
+
+type ProjectorParam struct {
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	Default     float64 `json:"default"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+	Description string  `json:"description"`
+}
+
+type ProjectorInfo struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Params      []ProjectorParam `json:"params"`
+}
+
+type SeriesPoint struct {
+	Bucket time.Time `json:"bucket"`
+	Value  *float64  `json:"value"`
+	Count  int       `json:"count"`
+}
+
+type Series struct {
+	DatasetId int           `json:"datasetId"`
+	Bucket    string        `json:"bucket"`
+	Fn        string        `json:"fn"`
+	Points    []SeriesPoint `json:"points"`
+}
+
+type ImportRowResult struct {
+	Row   int    `json:"row"`
+	Entry *Entry `json:"entry,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun   bool              `json:"dryRun"`
+	Total    int               `json:"total"`
+	Valid    int               `json:"valid"`
+	Invalid  int               `json:"invalid"`
+	Imported int               `json:"imported"`
+	Rows     []ImportRowResult `json:"rows"`
+}
+
+type BatchOperation struct {
+	Op      string `json:"op"`
+	Id      int    `json:"id,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Entry   *Entry `json:"entry,omitempty"`
+}
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchOperationResult struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	Id    int    `json:"id,omitempty"`
+	Entry *Entry `json:"entry,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type BatchReport struct {
+	Applied bool                   `json:"applied"`
+	Results []BatchOperationResult `json:"results"`
+}
+
+type DatasetExport struct {
+	Dataset Dataset `json:"dataset"`
+	Entries []Entry `json:"entries"`
+}
+
+type Backup struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Datasets  []DatasetExport `json:"datasets"`
+}
+
+type RestoredDataset struct {
+	ArchiveId int    `json:"archiveId"`
+	Id        int    `json:"id"`
+	Name      string `json:"name"`
+	Action    string `json:"action"`
+	Entries   int    `json:"entries"`
+}
+
+type RestoreReport struct {
+	Strategy string            `json:"strategy"`
+	Datasets []RestoredDataset `json:"datasets"`
+}
+
+type User struct {
+	Id           int       `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type Session struct {
+	TokenHash string
+	UserId    int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	User      User      `json:"user"`
+}
+
+type DatasetMember struct {
+	DatasetId int    `json:"datasetId"`
+	UserId    int    `json:"userId"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+}
+
+type MemberInvite struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type APIKey struct {
+	Id         int        `json:"id"`
+	DatasetId  int        `json:"datasetId"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	CreatedBy  int        `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+type APIKeyRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type Actor struct {
+	UserId   int
+	APIKeyId int
+	Name     string
+}
+
+type AuditEvent struct {
+	Id        int             `json:"id"`
+	DatasetId int             `json:"datasetId"`
+	EntryId   int             `json:"entryId,omitempty"`
+	Entity    string          `json:"entity"`
+	Action    string          `json:"action"`
+	UserId    int             `json:"userId,omitempty"`
+	APIKeyId  int             `json:"apiKeyId,omitempty"`
+	Actor     string          `json:"actor"`
+	OldValue  json.RawMessage `json:"oldValue,omitempty"`
+	NewValue  json.RawMessage `json:"newValue,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type AuditFilter struct {
+	DatasetId int
+	EntryId   int
+	Limit     int
+	Offset    int
+}
+
+type Trash struct {
+	RetentionDays int       `json:"retentionDays"`
+	Datasets      []Dataset `json:"datasets"`
+	Entries       []Entry   `json:"entries"`
+}
+
+type AlertRule struct {
+	Id              int        `json:"id"`
+	DatasetId       int        `json:"datasetId"`
+	Name            string     `json:"name"`
+	Condition       string     `json:"condition"`
+	Threshold       *float64   `json:"threshold,omitempty"`
+	Direction       string     `json:"direction,omitempty"`
+	Days            int        `json:"days,omitempty"`
+	Method          string     `json:"method,omitempty"`
+	Notifier        string     `json:"notifier"`
+	Target          string     `json:"target,omitempty"`
+	Firing          bool       `json:"firing"`
+	LastTriggeredAt *time.Time `json:"lastTriggeredAt"`
+	CreatedBy       int        `json:"createdBy"`
+	CreatedAt       time.Time  `json:"createdAt"`
+}
+
+type AlertRuleRequest struct {
+	Name      string   `json:"name"`
+	Condition string   `json:"condition"`
+	Threshold *float64 `json:"threshold"`
+	Direction string   `json:"direction"`
+	Days      int      `json:"days"`
+	Method    string   `json:"method"`
+	Notifier  string   `json:"notifier"`
+	Target    string   `json:"target"`
+}
+
+type Alert struct {
 	Id        int       `json:"id"`
+	RuleId    int       `json:"ruleId"`
 	DatasetId int       `json:"datasetId"`
-	Value     float64   `json:"value"`
-	Label     string    `json:"label"`
-	Date      time.Time `json:"date"`
-	Projected bool      `json:"projected,omitempty"`
+	RuleName  string    `json:"ruleName"`
+	Condition string    `json:"condition"`
+	Message   string    `json:"message"`
+	Value     *float64  `json:"value,omitempty"`
+	Notifier  string    `json:"notifier"`
+	Delivered bool      `json:"delivered"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type AlertFilter struct {
+	DatasetId int
+	RuleId    int
+	Limit     int
+	Offset    int
 }
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"backend/models"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// emailTimeout limits how long sending an alert by email may take, from connecting to the end of the conversation
+const emailTimeout = 30 * time.Second
+
+// SMTPConfig is the mail server emails are sent through
+type SMTPConfig struct {
+	Host string
+	Port string
+	// Username and Password authenticate with the server if Username is set
+	Username string
+	Password string
+	From     string
+}
+
+// Email sends alerts by email to the address given as target
+type Email struct {
+	config SMTPConfig
+}
+
+// NewEmail returns an email notifier sending through the server of config
+func NewEmail(config SMTPConfig) *Email {
+	return &Email{config: config}
+}
+
+func (*Email) Name() string { return models.NotifierEmail }
+
+func (n *Email) Notify(target string, alert models.Alert) error {
+	return n.send(target, n.message(target, alert))
+}
+
+// send delivers a message like smtp.SendMail, but gives up once emailTimeout passed,
+// so a mail server that stops responding does not keep the alert from being recorded
+func (n *Email) send(to string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(n.config.Host, n.config.Port), emailTimeout)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(emailTimeout)); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.config.Host}); err != nil {
+			return err
+		}
+	}
+	if n.config.Username != "" {
+		auth := smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.config.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message formats an alert as email, keeping line breaks out of the headers.
+// The subject is encoded as RFC 2047 encoded words, since rule names may contain any character.
+func (n *Email) message(to string, alert models.Alert) []byte {
+	header := strings.NewReplacer("\r", " ", "\n", " ")
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", header.Replace(n.config.From))
+	fmt.Fprintf(&b, "To: %s\r\n", header.Replace(to))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Alert: "+header.Replace(alert.RuleName)))
+	fmt.Fprintf(&b, "Date: %s\r\n", alert.CreatedAt.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(alert.Message, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
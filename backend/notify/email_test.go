@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"backend/models"
+	"bufio"
+	"bytes"
+	"mime"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+)
+
+func TestEmailMessageEncodesSubject(t *testing.T) {
+	tests := []struct {
+		ruleName string
+		subject  string
+	}{
+		{"Budget", "Alert: Budget"},
+		{"Stromzähler über 500 kWh", "Alert: Stromzähler über 500 kWh"},
+		{"Budget\r\nBcc: victim@example.com", "Alert: Budget  Bcc: victim@example.com"},
+	}
+	email := NewEmail(SMTPConfig{From: "alerts@example.com"})
+	for _, tt := range tests {
+		msg := email.message("user@example.com", models.Alert{RuleName: tt.ruleName, Message: "message", CreatedAt: time.Now()})
+		header, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(msg))).ReadMIMEHeader()
+		if err != nil {
+			t.Fatalf("%q: %v", tt.ruleName, err)
+		}
+		if bcc := header.Get("Bcc"); bcc != "" {
+			t.Errorf("%q: injected Bcc header %q", tt.ruleName, bcc)
+		}
+		raw := header.Get("Subject")
+		if strings.ContainsFunc(raw, func(r rune) bool { return r > unicode.MaxASCII }) {
+			t.Errorf("%q: subject %q is not encoded", tt.ruleName, raw)
+		}
+		subject, err := new(mime.WordDecoder).DecodeHeader(raw)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.ruleName, err)
+		}
+		if subject != tt.subject {
+			t.Errorf("%q: got subject %q, want %q", tt.ruleName, subject, tt.subject)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Package notify delivers the alerts raised by alert rules
+package notify
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+)
+
+// Notifier delivers alerts through one channel, like a webhook or email
+type Notifier interface {
+	// Name is the name alert rules select the notifier by
+	Name() string
+	// Notify delivers an alert to the target of its rule, the webhook URL or email address
+	Notify(target string, alert models.Alert) error
+}
+
+// Log writes alerts to the server log, the target is ignored
+type Log struct{}
+
+func (Log) Name() string { return models.NotifierLog }
+
+func (Log) Notify(_ string, alert models.Alert) error {
+	utils.Warning(fmt.Sprintf("Alert %q: %s", alert.RuleName, alert.Message))
+	return nil
+}
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"backend/models"
+	"backend/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// webhookTimeout limits how long delivering an alert to a webhook may take
+const webhookTimeout = 10 * time.Second
+
+// ErrBlockedAddress is returned for webhooks on an address of the server's own or a private network
+var ErrBlockedAddress = errors.New("webhook address is not public")
+
+// Webhook posts alerts as JSON to the URL given as target.
+// Any user may create alert rules, so webhooks are only delivered to public addresses, never to the
+// server itself, private networks or cloud metadata endpoints, and redirects are not followed.
+type Webhook struct {
+	client *http.Client
+}
+
+// webhookPayload is the JSON body posted for an alert, without the delivery details recorded afterwards
+type webhookPayload struct {
+	RuleId    int       `json:"ruleId"`
+	DatasetId int       `json:"datasetId"`
+	RuleName  string    `json:"ruleName"`
+	Condition string    `json:"condition"`
+	Message   string    `json:"message"`
+	Value     *float64  `json:"value,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewWebhook returns a webhook notifier
+func NewWebhook() *Webhook {
+	// The address is checked after the host name was resolved, right before connecting,
+	// so a name resolving to a blocked address, or changing to one later, is caught as well
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: checkPublicAddress}
+	transport := &http.Transport{
+		// Connecting through a proxy would check the address of the proxy instead of the webhook
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+	}
+	return &Webhook{client: &http.Client{
+		Transport: transport,
+		Timeout:   webhookTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+func (*Webhook) Name() string { return models.NotifierWebhook }
+
+func (n *Webhook) Notify(target string, alert models.Alert) error {
+	body, err := json.Marshal(webhookPayload{
+		RuleId:    alert.RuleId,
+		DatasetId: alert.DatasetId,
+		RuleName:  alert.RuleName,
+		Condition: alert.Condition,
+		Message:   alert.Message,
+		Value:     alert.Value,
+		CreatedAt: alert.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	res, err := n.client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// checkPublicAddress is the dialer control function rejecting connections to addresses that are not public
+func checkPublicAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !utils.PublicAddress(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"backend/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookRejectsLoopback(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer server.Close()
+	// Resolving the name to the loopback address must be caught as well
+	target := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	for _, url := range []string{server.URL, target} {
+		err := NewWebhook().Notify(url, models.Alert{RuleName: "test"})
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("%s: got %v, want %v", url, err, ErrBlockedAddress)
+		}
+	}
+	if called {
+		t.Error("webhook on the loopback address was called")
+	}
+}
+
+func TestWebhookDoesNotFollowRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer server.Close()
+	n := NewWebhook()
+	// The test server is on the loopback address, so only the redirect handling of the client is used
+	n.client.Transport = http.DefaultTransport
+
+	err := n.Notify(server.URL, models.Alert{RuleName: "test"})
+	if err == nil || !strings.Contains(err.Error(), "302") {
+		t.Errorf("got %v, want the redirect status as error", err)
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import "net/netip"
+
+// reservedPrefixes are ranges that are not public but which net/netip counts as global unicast:
+// this network, carrier-grade NAT, IETF protocol assignments, benchmarking and NAT64, which may
+// translate to private IPv4 addresses
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// PublicAddress reports whether addr is public, so the server may connect to it on behalf of its users.
+// Loopback, private, link-local including cloud metadata endpoints, multicast, unspecified and other
+// reserved addresses are not.
+func PublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 func ConnectDB() (*sql.DB, error) {
@@ -28,6 +29,26 @@ func ConnectDB() (*sql.DB, error) {
 	return db, nil
 }
 
+// ConnectSQLite opens the SQLite database file at path, creating it if it does not exist
+func ConnectSQLite(path string) (*sql.DB, error) {
+	Info("Opening SQLite database " + path + "...")
+	// Times are written in a format SQLite can compare, foreign keys enable ON DELETE CASCADE
+	db, err := sql.Open("sqlite", "file:"+path+
+		"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, one connection avoids transactions failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if pErr := db.Ping(); pErr != nil {
+		return nil, pErr
+	}
+
+	Success("Opened SQLite database.")
+	return db, nil
+}
+
 func DisconnectDB(db *sql.DB) {
 	err := db.Close()
 	if err != nil {
@@ -0,0 +1,273 @@
+package validation
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxNameLength        = 200
+	maxDescriptionLength = 2000
+	maxSymbolLength      = 20
+	maxLabelLength       = 200
+	maxKeyNameLength     = 100
+	maxRuleNameLength    = 100
+	maxTargetLength      = 500
+	// maxAlertDays is the longest period without entries an alert rule may wait for, ten years
+	maxAlertDays = 3650
+
+	minUsernameLength = 3
+	maxUsernameLength = 50
+	minPasswordLength = 8
+	// maxPasswordBytes is the most bcrypt hashes, longer passwords would be cut off silently
+	maxPasswordBytes = 72
+
+	// maxAbsValue is the largest magnitude a NUMERIC(15,2) column holds
+	maxAbsValue = 1e13
+)
+
+// Error lists the fields of a dataset or entry that are invalid
+type Error struct {
+	Fields []models.FieldError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + " " + f.Message
+	}
+	return strings.Join(messages, ", ")
+}
+
+func (e *Error) add(field, message string) {
+	e.Fields = append(e.Fields, models.FieldError{Field: field, Message: message})
+}
+
+// err returns e if any field is invalid and nil otherwise
+func (e *Error) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Dataset trims the name of d, defaults its kind to cumulative and checks its fields.
+// Returns an *Error listing the invalid fields, or nil if d is valid.
+func Dataset(d *models.Dataset) error {
+	v := &Error{}
+
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		v.add("name", "is required")
+	}
+	checkLength(v, "name", d.Name, maxNameLength)
+	checkLength(v, "description", d.Description, maxDescriptionLength)
+	checkLength(v, "symbol", d.Symbol, maxSymbolLength)
+
+	switch d.Kind {
+	case "":
+		d.Kind = models.KindCumulative
+	case models.KindCumulative, models.KindIncremental:
+	default:
+		v.add("kind", "must be cumulative or incremental")
+	}
+
+	if d.TargetValue != nil {
+		checkValue(v, "targetValue", *d.TargetValue)
+	}
+	if d.StartDate != nil && d.EndDate != nil && dateOnly(*d.EndDate).Before(dateOnly(*d.StartDate)) {
+		v.add("endDate", "must not be before startDate")
+	}
+	return v.err()
+}
+
+// Entry checks the fields of e and, if dataset is given, that e is dated within its start and end date.
+// Returns an *Error listing the invalid fields, or nil if e is valid.
+func Entry(e *models.Entry, dataset *models.Dataset) error {
+	v := &Error{}
+
+	checkValue(v, "value", e.Value)
+	checkLength(v, "label", e.Label, maxLabelLength)
+
+	if e.Date.IsZero() {
+		v.add("date", "is required")
+	} else if dataset != nil {
+		day := dateOnly(e.Date)
+		if dataset.StartDate != nil && day.Before(dateOnly(*dataset.StartDate)) {
+			v.add("date", "must not be before the start date of the dataset, "+dataset.StartDate.Format(time.DateOnly))
+		}
+		if dataset.EndDate != nil && day.After(dateOnly(*dataset.EndDate)) {
+			v.add("date", "must not be after the end date of the dataset, "+dataset.EndDate.Format(time.DateOnly))
+		}
+	}
+	return v.err()
+}
+
+// Credentials trims and lowercases the username of c, so usernames are unique regardless of case, and checks
+// that it only contains letters, digits, dots, dashes and underscores and that the password is long enough.
+// Returns an *Error listing the invalid fields, or nil if c is valid.
+func Credentials(c *models.Credentials) error {
+	v := &Error{}
+
+	c.Username = strings.ToLower(strings.TrimSpace(c.Username))
+	switch {
+	case utf8.RuneCountInString(c.Username) < minUsernameLength:
+		v.add("username", fmt.Sprintf("must be at least %d characters", minUsernameLength))
+	case strings.IndexFunc(c.Username, invalidUsernameRune) >= 0:
+		v.add("username", "may only contain letters, digits, dots, dashes and underscores")
+	}
+	checkLength(v, "username", c.Username, maxUsernameLength)
+
+	switch {
+	case utf8.RuneCountInString(c.Password) < minPasswordLength:
+		v.add("password", fmt.Sprintf("must be at least %d characters", minPasswordLength))
+	case len(c.Password) > maxPasswordBytes:
+		v.add("password", fmt.Sprintf("must be at most %d bytes", maxPasswordBytes))
+	}
+	return v.err()
+}
+
+// MemberInvite trims and lowercases the username of an invitation like Credentials and checks that it
+// grants the editor or viewer role. Datasets have exactly one owner, so the owner role cannot be granted.
+// Returns an *Error listing the invalid fields, or nil if m is valid.
+func MemberInvite(m *models.MemberInvite) error {
+	v := &Error{}
+
+	m.Username = strings.ToLower(strings.TrimSpace(m.Username))
+	if m.Username == "" {
+		v.add("username", "is required")
+	}
+	if m.Role != models.RoleEditor && m.Role != models.RoleViewer {
+		v.add("role", "must be editor or viewer")
+	}
+	return v.err()
+}
+
+// APIKey trims the name of an API key request and checks it and that the scope is read or write.
+// Returns an *Error listing the invalid fields, or nil if k is valid.
+func APIKey(k *models.APIKeyRequest) error {
+	v := &Error{}
+
+	k.Name = strings.TrimSpace(k.Name)
+	if k.Name == "" {
+		v.add("name", "is required")
+	}
+	checkLength(v, "name", k.Name, maxKeyNameLength)
+	if k.Scope != models.ScopeRead && k.Scope != models.ScopeWrite {
+		v.add("scope", "must be read or write")
+	}
+	return v.err()
+}
+
+// AlertRule trims the name and target of an alert rule request and checks the fields its condition needs.
+// notifiers and methods are the names of the available notifiers and projection methods.
+// Fields the condition or notifier does not use are cleared.
+// Returns an *Error listing the invalid fields, or nil if rule is valid.
+func AlertRule(rule *models.AlertRuleRequest, notifiers, methods []string) error {
+	v := &Error{}
+
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		v.add("name", "is required")
+	}
+	checkLength(v, "name", rule.Name, maxRuleNameLength)
+
+	switch rule.Condition {
+	case models.AlertThreshold:
+		if rule.Threshold == nil {
+			v.add("threshold", "is required")
+		} else {
+			checkValue(v, "threshold", *rule.Threshold)
+		}
+		if rule.Direction != models.DirectionAbove && rule.Direction != models.DirectionBelow {
+			v.add("direction", "must be above or below")
+		}
+	case models.AlertNoEntries:
+		if rule.Days < 1 || rule.Days > maxAlertDays {
+			v.add("days", fmt.Sprintf("must be between 1 and %d", maxAlertDays))
+		}
+	case models.AlertTargetReached, models.AlertDeadlineMissed:
+	default:
+		v.add("condition", "must be threshold, target_reached, deadline_missed or no_entries")
+	}
+	if rule.Condition != models.AlertThreshold {
+		rule.Threshold, rule.Direction = nil, ""
+	}
+	if rule.Condition != models.AlertNoEntries {
+		rule.Days = 0
+	}
+	if rule.Condition != models.AlertDeadlineMissed {
+		rule.Method = ""
+	} else if !slices.Contains(methods, rule.Method) {
+		v.add("method", "must be one of "+strings.Join(methods, ", "))
+	}
+
+	if !slices.Contains(notifiers, rule.Notifier) {
+		v.add("notifier", "must be one of "+strings.Join(notifiers, ", "))
+	}
+	rule.Target = strings.TrimSpace(rule.Target)
+	checkLength(v, "target", rule.Target, maxTargetLength)
+	switch rule.Notifier {
+	case models.NotifierWebhook:
+		u, err := url.Parse(rule.Target)
+		switch {
+		case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "":
+			v.add("target", "must be an http or https URL")
+		case !publicHost(u.Hostname()):
+			v.add("target", "must not point to the server itself or a private network")
+		}
+	case models.NotifierEmail:
+		if addr, err := mail.ParseAddress(rule.Target); err != nil || addr.Address != rule.Target {
+			v.add("target", "must be an email address")
+		}
+	default:
+		rule.Target = ""
+	}
+	return v.err()
+}
+
+// publicHost reports whether a webhook host may be public. Host names are checked again once
+// they are resolved, this only rejects what is known not to be public without resolving it.
+func publicHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return utils.PublicAddress(addr)
+	}
+	return true
+}
+
+func invalidUsernameRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_')
+}
+
+func checkLength(v *Error, field, value string, maxLength int) {
+	if utf8.RuneCountInString(value) > maxLength {
+		v.add(field, fmt.Sprintf("must be at most %d characters", maxLength))
+	}
+}
+
+func checkValue(v *Error, field string, value float64) {
+	switch {
+	case math.IsNaN(value) || math.IsInf(value, 0):
+		v.add(field, "must be a finite number")
+	case math.Abs(value) >= maxAbsValue:
+		v.add(field, fmt.Sprintf("must be between %g and %g", -maxAbsValue, maxAbsValue))
+	}
+}
+
+// dateOnly returns the calendar date of t, ignoring its time of day and location
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -1,10 +1,13 @@
 import { Routes } from '@angular/router';
 import { DatasetForm } from './datasets/dataset-form';
 import { DatasetEntries } from './datasets/dataset-entries';
+import { Login } from './auth/login';
+import { authGuard } from './services/auth.guard';
 
 export const routes: Routes = [
   { path: '', redirectTo: 'datasets/new', pathMatch: 'full' },
-  { path: 'datasets/new', component: DatasetForm },
-  { path: 'datasets/:id', component: DatasetEntries },
+  { path: 'login', component: Login },
+  { path: 'datasets/new', component: DatasetForm, canActivate: [authGuard] },
+  { path: 'datasets/:id', component: DatasetEntries, canActivate: [authGuard] },
   { path: 'datasets/:id/edit', redirectTo: 'datasets/:id', pathMatch: 'full' },
 ];
@@ -4,6 +4,7 @@ import {MainContent} from './main-content/main-content';
 import {Alert} from './alert/alert';
 import {AlertType, UiEventsService} from './services/ui-events.service';
 import {MessageDialog} from './message-dialog/message-dialog';
+import {AuthService} from './services/auth.service';
 
 @Component({
   selector: 'app-root',
@@ -19,7 +20,7 @@ export class App implements OnInit  {
   alertMessage = signal<string>('');
   alertType = signal<AlertType>('info');
 
-  constructor(private readonly ui: UiEventsService) {}
+  constructor(private readonly ui: UiEventsService, protected readonly auth: AuthService) {}
 
   @HostListener('window:resize')
   onResize() {
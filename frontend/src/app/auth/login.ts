@@ -0,0 +1,102 @@
+import { Component, OnInit, signal } from '@angular/core';
+import { CommonModule } from '@angular/common';
+import { FormBuilder, FormGroup, ReactiveFormsModule, Validators } from '@angular/forms';
+import { ActivatedRoute, Router } from '@angular/router';
+import { HttpErrorResponse } from '@angular/common/http';
+
+import { AuthService } from '../services/auth.service';
+import { UiEventsService } from '../services/ui-events.service';
+import { MESSAGES, UI_TEXT } from '../services/message-service';
+import { Credentials } from '../models/user-model';
+
+type LoginMode = 'login' | 'register';
+
+@Component({
+  selector: 'app-login',
+  standalone: true,
+  imports: [CommonModule, ReactiveFormsModule],
+  templateUrl: './login.html',
+  styleUrls: ['./login.css'],
+})
+export class Login implements OnInit {
+  form!: FormGroup;
+  mode = signal<LoginMode>('login');
+  loading = signal<boolean>(false);
+  errorMessage = signal<string>('');
+
+  constructor(
+    private readonly fb: FormBuilder,
+    private readonly auth: AuthService,
+    private readonly route: ActivatedRoute,
+    private readonly router: Router,
+    private readonly ui: UiEventsService
+  ) {}
+
+  // ===== Lifecycle =====
+  ngOnInit(): void {
+    // Limits match the backend validation of usernames and passwords
+    this.form = this.fb.group({
+      username: ['', [Validators.required, Validators.minLength(3), Validators.maxLength(50)]],
+      password: ['', [Validators.required, Validators.minLength(8)]],
+    });
+
+    if (this.auth.token) {
+      this.navigateBack();
+    }
+  }
+
+  toggleMode(): void {
+    this.mode.set(this.mode() === 'login' ? 'register' : 'login');
+    this.errorMessage.set('');
+  }
+
+  // ===== Submit logic =====
+  submit(): void {
+    if (this.form.invalid) {
+      this.form.markAllAsTouched();
+      return;
+    }
+
+    const credentials = this.form.value as Credentials;
+    const request = this.mode() === 'login' ? this.auth.login(credentials) : this.auth.register(credentials);
+    this.loading.set(true);
+    this.errorMessage.set('');
+
+    request.subscribe({
+      next: () => {
+        this.ui.showAlert('success', this.mode() === 'login' ? MESSAGES.loggedIn : MESSAGES.registered);
+        this.navigateBack();
+      },
+      error: (err) => {
+        this.errorMessage.set(this.toMessage(err));
+        this.loading.set(false);
+      },
+      complete: () => this.loading.set(false),
+    });
+  }
+
+  // ===== Helpers =====
+  private navigateBack(): void {
+    const returnUrl = this.route.snapshot.queryParamMap.get('returnUrl');
+    // Only follow app-internal paths, so the parameter cannot redirect to another site
+    const target = returnUrl?.startsWith('/') && !returnUrl.startsWith('//') ? returnUrl : '/';
+    this.router.navigateByUrl(target).catch(() => this.router.navigateByUrl('/'));
+  }
+
+  private toMessage(err: unknown): string {
+    if (!(err instanceof HttpErrorResponse)) return MESSAGES.loginError;
+    switch (err.status) {
+      case 401:
+        return MESSAGES.invalidCredentials;
+      case 409:
+        return MESSAGES.usernameTaken;
+      case 422:
+        return err.error?.message ?? MESSAGES.registerError;
+      default:
+        return this.mode() === 'login' ? MESSAGES.loginError : MESSAGES.registerError;
+    }
+  }
+
+  // UI constants
+  protected readonly UI_TEXT = UI_TEXT;
+}
@@ -9,7 +9,7 @@ import { ApiService } from '../services/api.service';
 import { DateUtils } from '../services/date-utils';
 import { MESSAGES, UI_TEXT } from '../services/message-service';
 
-import { Dataset } from '../models/dataset-model';
+import { Dataset, DatasetKind } from '../models/dataset-model';
 
 @Component({
   selector: 'app-dataset-form',
@@ -63,6 +63,7 @@ export class DatasetForm implements OnInit, OnDestroy {
       targetValue: [null as number | null],
       startDate: [null as string | null],
       endDate: [null as string | null],
+      kind: ['cumulative' as DatasetKind],
     });
   }
 
@@ -76,6 +77,7 @@ export class DatasetForm implements OnInit, OnDestroy {
       targetValue: null,
       startDate: null,
       endDate: null,
+      kind: 'cumulative',
     });
   }
 
@@ -91,6 +93,7 @@ export class DatasetForm implements OnInit, OnDestroy {
           targetValue: data.targetValue ?? null,
           startDate: data.startDate ? DateUtils.toDateInputValue(data.startDate) : null,
           endDate: data.endDate ? DateUtils.toDateInputValue(data.endDate) : null,
+          kind: data.kind ?? 'cumulative',
         });
       },
       error: (err) => this.handleError(err, MESSAGES.loadDatasetError),
@@ -251,6 +254,7 @@ export class DatasetForm implements OnInit, OnDestroy {
       targetValue: number | null;
       startDate: string | null;
       endDate: string | null;
+      kind: DatasetKind;
     };
 
     return {
@@ -260,6 +264,7 @@ export class DatasetForm implements OnInit, OnDestroy {
       targetValue: v.targetValue != null && v.targetValue !== ('' as any) ? Number(v.targetValue) : null,
       startDate: v.startDate ? DateUtils.toISOString(v.startDate) : null,
       endDate: v.endDate ? DateUtils.toISOString(v.endDate) : null,
+      kind: v.kind ?? 'cumulative',
     };
   }
 
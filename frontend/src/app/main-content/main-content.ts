@@ -14,7 +14,11 @@ import {NgClass} from '@angular/common';
 export class MainContent {
   isSidebarCollapsed = input.required<boolean>();
   screenWidth = input.required<number>();
+  hasSidebar = input<boolean>(true);
   sizeClass = computed(() => {
+    if (!this.hasSidebar()) {
+      return 'body-full';
+    }
     const isSidebarCollapsed = this.isSidebarCollapsed();
     if (isSidebarCollapsed) {
       return '';
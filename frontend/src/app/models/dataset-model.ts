@@ -1,3 +1,5 @@
+export type DatasetKind = 'cumulative' | 'incremental';
+
 export interface Dataset {
   id?: number;
   name: string;
@@ -6,4 +8,5 @@ export interface Dataset {
   targetValue?: number | null;
   startDate?: string  | null;
   endDate?: string  | null;
+  kind?: DatasetKind;
 }
@@ -5,4 +5,6 @@ export interface Entry {
   label: string;
   date: string;
   projected?: boolean;
+  lower?: number;
+  upper?: number;
 }
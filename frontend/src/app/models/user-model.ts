@@ -0,0 +1,16 @@
+export interface User {
+  id: number;
+  username: string;
+  createdAt: string;
+}
+
+export interface Credentials {
+  username: string;
+  password: string;
+}
+
+export interface LoginResponse {
+  token: string;
+  expiresAt: string;
+  user: User;
+}
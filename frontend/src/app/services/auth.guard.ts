@@ -0,0 +1,12 @@
+import { inject } from '@angular/core';
+import { CanActivateFn, Router } from '@angular/router';
+
+import { AuthService } from './auth.service';
+
+/** Lets logged in users through and sends everyone else to the login page */
+export const authGuard: CanActivateFn = (_route, state) => {
+  if (inject(AuthService).token) {
+    return true;
+  }
+  return inject(Router).createUrlTree(['/login'], { queryParams: { returnUrl: state.url } });
+};
@@ -0,0 +1,52 @@
+import { Injectable } from '@angular/core';
+import { HttpErrorResponse, HttpEvent, HttpHandler, HttpInterceptor, HttpRequest } from '@angular/common/http';
+import { Router } from '@angular/router';
+import { Observable, catchError, throwError } from 'rxjs';
+
+import { AuthService } from './auth.service';
+import { UiEventsService } from './ui-events.service';
+import { MESSAGES } from './message-service';
+
+// Requests to these paths answer 401 for wrong credentials, which the login form reports itself
+const CREDENTIAL_PATHS = ['/api/auth/login', '/api/auth/register'];
+
+/** Adds the bearer token to API requests and sends the user to the login page when the session is rejected */
+@Injectable()
+export class AuthInterceptor implements HttpInterceptor {
+  constructor(
+    private readonly auth: AuthService,
+    private readonly router: Router,
+    private readonly ui: UiEventsService
+  ) {}
+
+  intercept(req: HttpRequest<unknown>, next: HttpHandler): Observable<HttpEvent<unknown>> {
+    if (!req.url.startsWith('/api/')) {
+      return next.handle(req);
+    }
+
+    const token = this.auth.token;
+    const authorized = token ? req.clone({ setHeaders: { Authorization: `Bearer ${token}` } }) : req;
+
+    return next.handle(authorized).pipe(
+      catchError((err: unknown) => {
+        if (err instanceof HttpErrorResponse && err.status === 401 && !CREDENTIAL_PATHS.includes(req.url)) {
+          this.sendToLogin();
+        }
+        return throwError(() => err);
+      })
+    );
+  }
+
+  private sendToLogin(): void {
+    const wasLoggedIn = this.auth.isLoggedIn();
+    this.auth.clearSession();
+    if (this.router.url.startsWith('/login')) return;
+
+    if (wasLoggedIn) {
+      this.ui.showAlert('warning', MESSAGES.sessionExpired);
+    }
+    this.router
+      .navigate(['/login'], { queryParams: { returnUrl: this.router.url } })
+      .catch(() => this.router.navigateByUrl('/login'));
+  }
+}
@@ -0,0 +1,65 @@
+import { Injectable, computed, signal } from '@angular/core';
+import { Observable, switchMap, tap } from 'rxjs';
+
+import { ApiService } from './api.service';
+import { Credentials, LoginResponse, User } from '../models/user-model';
+
+// Key under which the session is kept in localStorage, so it survives reloads
+const SESSION_KEY = 'dataTracker.session';
+
+@Injectable({ providedIn: 'root' })
+export class AuthService {
+  private readonly session = signal<LoginResponse | null>(this.restoreSession());
+
+  readonly user = computed<User | null>(() => this.session()?.user ?? null);
+  readonly isLoggedIn = computed<boolean>(() => this.session() !== null);
+
+  constructor(private readonly api: ApiService) {}
+
+  /** Bearer token of the current session, or null if the user is not logged in or it expired */
+  get token(): string | null {
+    const session = this.session();
+    if (!session) return null;
+    if (new Date(session.expiresAt).getTime() <= Date.now()) {
+      this.clearSession();
+      return null;
+    }
+    return session.token;
+  }
+
+  login(credentials: Credentials): Observable<LoginResponse> {
+    return this.api.post<LoginResponse>('/auth/login', credentials).pipe(
+      tap((session) => {
+        localStorage.setItem(SESSION_KEY, JSON.stringify(session));
+        this.session.set(session);
+      })
+    );
+  }
+
+  /** Creates an account and logs in with it */
+  register(credentials: Credentials): Observable<LoginResponse> {
+    return this.api.post<User>('/auth/register', credentials).pipe(switchMap(() => this.login(credentials)));
+  }
+
+  /** Ends the session on the server, the local session is cleared even if that fails */
+  logout(): Observable<void> {
+    return this.api.post<void>('/auth/logout', null).pipe(tap({ finalize: () => this.clearSession() }));
+  }
+
+  /** Forgets the session without contacting the server, e.g. after it was rejected */
+  clearSession(): void {
+    localStorage.removeItem(SESSION_KEY);
+    this.session.set(null);
+  }
+
+  private restoreSession(): LoginResponse | null {
+    try {
+      const session = JSON.parse(localStorage.getItem(SESSION_KEY) ?? 'null') as LoginResponse | null;
+      if (session?.token && new Date(session.expiresAt).getTime() > Date.now()) {
+        return session;
+      }
+    } catch {}
+    localStorage.removeItem(SESSION_KEY);
+    return null;
+  }
+}
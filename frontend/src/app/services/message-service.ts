@@ -19,7 +19,15 @@ export const MESSAGES = {
   actual: 'Reale Werte',
   projected: 'Projektierte Werte',
   datasetCopied: 'Datensatz kopiert.',
-  entryCopyError: 'Eintrag konnte nicht kopiert werden.'
+  entryCopyError: 'Eintrag konnte nicht kopiert werden.',
+  loggedIn: 'Angemeldet.',
+  loggedOut: 'Abgemeldet.',
+  registered: 'Konto erstellt und angemeldet.',
+  loginError: 'Anmeldung fehlgeschlagen.',
+  registerError: 'Konto konnte nicht erstellt werden.',
+  invalidCredentials: 'Benutzername oder Passwort ist falsch.',
+  usernameTaken: 'Dieser Benutzername ist bereits vergeben.',
+  sessionExpired: 'Ihre Sitzung ist abgelaufen. Bitte melden Sie sich erneut an.',
 };
 
 export const UI_TEXT = {
@@ -36,6 +44,8 @@ export const UI_TEXT = {
     edit: 'Bearbeiten',
     chartNoData: 'Keine Daten zum Anzeigen.',
     confirmDelete: 'Wollen Sie das wirklich löschen?',
+    login: 'Anmelden',
+    register: 'Konto erstellen',
   },
   labels: {
     name: 'Name',
@@ -44,12 +54,19 @@ export const UI_TEXT = {
     targetValue: 'Zielwert (Optional)',
     startDate: 'Startdatum (Optional)',
     endDate: 'Enddatum (Optional)',
+    kind: 'Art der Werte',
     confirmDeleteDataset: 'Bestätigen Sie das Löschen des Datensatzes.',
     confirmDeleteEntry: 'Bestätigen Sie das Löschen des Eintrags.',
     search: 'Suche',
     from: 'Von',
     to: 'Bis',
     sortBy: 'Sortieren nach',
+    username: 'Benutzername',
+    password: 'Passwort',
+  },
+  kinds: {
+    cumulative: 'Kumulativ (Stand, z. B. Kontostand)',
+    incremental: 'Inkrementell (Zuwachs, z. B. Tagesumsatz)',
   },
   tabs: {
     data: 'Daten',
@@ -58,6 +75,8 @@ export const UI_TEXT = {
   errors: {
     nameRequired: 'Name ist erforderlich.',
     symbolRequired: 'Symbol ist erforderlich.',
+    usernameInvalid: 'Benutzername muss 3 bis 50 Zeichen lang sein.',
+    passwordInvalid: 'Passwort muss mindestens 8 Zeichen lang sein.',
   },
   table: {
     label: 'Bezeichnung',
@@ -78,6 +97,11 @@ export const UI_TEXT = {
     createCopy: 'Kopie erstellen',
     clearFilters: 'Filter leeren',
     exportCsv: 'CSV exportieren',
+    login: 'Anmelden',
+    register: 'Registrieren',
+    logout: 'Abmelden',
+    toRegister: 'Noch kein Konto? Registrieren',
+    toLogin: 'Bereits registriert? Anmelden',
   },
   placeholders: {
     label: 'Bezeichnung',
@@ -146,5 +170,6 @@ export const UI_TEXT = {
       createCopy: 'Eine Kopie dieses Datensatzes erstellen',
       deleteDataset: 'Diesen Datensatz löschen',
     },
+    logout: 'Von diesem Konto abmelden',
   },
 };
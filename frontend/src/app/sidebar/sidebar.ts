@@ -1,10 +1,11 @@
 import {CommonModule} from '@angular/common';
 import {Component, OnInit, input, output} from '@angular/core';
-import {RouterModule} from '@angular/router';
+import {Router, RouterModule} from '@angular/router';
 import {Dataset} from '../models/dataset-model';
 import {AlertType, UiEventsService} from '../services/ui-events.service';
 import { MESSAGES, UI_TEXT} from '../services/message-service';
 import {ApiService} from '../services/api.service';
+import {AuthService} from '../services/auth.service';
 
 @Component({
   selector: 'app-sidebar',
@@ -19,7 +20,12 @@ export class Sidebar implements OnInit {
   // Emits alerts to the parent (App)
   notify = output<{ type: AlertType; message: string }>();
 
-  constructor(private readonly api: ApiService, private readonly ui: UiEventsService) {}
+  constructor(
+    private readonly api: ApiService,
+    private readonly ui: UiEventsService,
+    protected readonly auth: AuthService,
+    private readonly router: Router
+  ) {}
 
   ngOnInit(): void {
     this.loadDatasets();
@@ -57,6 +63,14 @@ export class Sidebar implements OnInit {
     this.changeIsSidebarCollapsed.emit(!this.isSidebarCollapsed());
   }
 
+  logout(): void {
+    this.auth.logout().subscribe({
+      error: (err) => console.error('Error logging out:', err),
+    });
+    this.notify.emit({ type: 'info', message: MESSAGES.loggedOut });
+    this.router.navigateByUrl('/login').catch(() => this.router.navigateByUrl('/'));
+  }
+
   closeSidenav(): void {
     this.changeIsSidebarCollapsed.emit(true);
   }